@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/couchbaselabs/sdk-doctor/doctor"
+	"github.com/spf13/cobra"
+)
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare <before.json> <after.json>",
+	Short: "Compare shows what changed between two diagnose runs",
+	Long: `Compare reads two reports written by diagnose --format json and
+prints the warnings and errors which appeared or were resolved, how the KV
+latency of each node changed, and which nodes and endpoints changed.  This
+confirms whether a firewall or DNS change fixed (or broke) something.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCompare,
+}
+
+func init() {
+	RootCmd.AddCommand(compareCmd)
+}
+
+func readReportFile(path string) (doctor.Report, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return doctor.Report{}, fmt.Errorf("failed to open report: %s", err)
+	}
+	defer file.Close()
+
+	report, err := doctor.ReadReport(file)
+	if err != nil {
+		return report, fmt.Errorf("failed to read report `%s`, expected the output of --format json: %s", path, err)
+	}
+	return report, nil
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	before, err := readReportFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	after, err := readReportFile(args[1])
+	if err != nil {
+		return err
+	}
+
+	after.PrintComparison(os.Stdout, before)
+	return nil
+}
@@ -1,816 +1,464 @@
 package cmd
 
 import (
-	"bytes"
+	"bufio"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"net"
-	"net/http"
+	"os"
+	"os/signal"
 	"strings"
 	"time"
 
-	"github.com/couchbaselabs/gocbconnstr"
-	"github.com/couchbaselabs/sdk-doctor/helpers"
+	"github.com/couchbaselabs/sdk-doctor/doctor"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
-func stripIPv6Address(address string) string {
-	if strings.HasPrefix(address, "[") && strings.HasSuffix(address, "]") {
-		return address[1 : len(address)-1]
-	}
-	return address
-}
-
 // diagnoseCmd represents the diagnose command
 var diagnoseCmd = &cobra.Command{
-	Use:   "diagnose [connection_string]",
+	Use:   "diagnose [connection_string | -]",
 	Short: "Diagnose checks for problems with your configuration",
 	Long: `Diagnose runs various tests against your network and cluster
 to identify any flaws in your configuration that would cause failures
-in development or production environments.`,
+in development or production environments.
+
+Passing - as the connection string (or using --stdin) reads it from the
+first line of stdin instead, keeping it out of the process list.  The
+username and password may follow on the next two lines, they are used
+unless --username or --password is given.`,
 	RunE: runDiagnose,
 }
 
 var (
-	tlsCaArg          string
-	usernameArg       string
-	passwordArg       string
-	bucketPasswordArg string
+	tlsCaArg            string
+	tlsSNIArg           string
+	usernameArg         string
+	passwordArg         string
+	bucketPasswordArg   string
+	bucketArg           string
+	scopeArg            string
+	sdkArg              string
+	expectUUIDArg       string
+	collectionArg       string
+	formatArg           string
+	groupByArg          string
+	idleTestArg         time.Duration
+	intervalArg         time.Duration
+	localAddrArg        string
+	maxHostsArg         int
+	maxSeedHostsArg     int
+	pingCountArg        int
+	mgmtPortArg         int
+	durabilityArg       string
+	printConfigArg      string
+	outFileArg          string
+	selfTestArg         bool
+	traceHTTPArg        bool
+	redactArg           bool
+	socks5Arg           string
+	dnsServerArg        string
+	suggestArg          bool
+	stdinArg            bool
+	promptArg           bool
+	quietArg            bool
+	topologyDotArg      string
+	continueArg         bool
+	queryNodesArg       bool
+	queryKeyspacesArg   bool
+	listEndpointsArg    bool
+	allBucketsArg       bool
+	labelArg            string
+	connectTimeoutArg   time.Duration
+	timeoutArg          time.Duration
+	dnsTimeoutArg       time.Duration
+	bootstrapTimeoutArg time.Duration
+	probeTimeoutArg     time.Duration
 )
 
 func init() {
 	RootCmd.AddCommand(diagnoseCmd)
 
 	diagnoseCmd.PersistentFlags().StringVarP(&tlsCaArg, "tls-ca", "a", "", "certificate authority")
+	diagnoseCmd.PersistentFlags().StringVar(&tlsSNIArg, "tls-sni", "", "server name to present and verify in TLS handshakes, instead of the hostname connected to")
 	diagnoseCmd.PersistentFlags().StringVarP(&usernameArg, "username", "u", "", "username")
 	diagnoseCmd.PersistentFlags().StringVarP(&passwordArg, "password", "p", "", "password")
+	diagnoseCmd.PersistentFlags().StringVar(&bucketArg, "bucket", "", "bucket to diagnose, when the connection string does not name one")
 	diagnoseCmd.PersistentFlags().StringVarP(&bucketPasswordArg, "bucket-password", "z", "", "bucket password (deprecated, use password instead)")
+	diagnoseCmd.PersistentFlags().StringVar(&sdkArg, "sdk", "", "SDK and version the application uses, to adjust the checks to it (e.g. java/3.4)")
+	diagnoseCmd.PersistentFlags().StringVar(&expectUUIDArg, "expect-uuid", "", "UUID of the cluster the connection string is expected to point at")
+	diagnoseCmd.PersistentFlags().StringVar(&scopeArg, "scope", "", "scope to verify exists (7.0+)")
+	diagnoseCmd.PersistentFlags().StringVar(&collectionArg, "collection", "", "collection to verify exists (7.0+)")
+	diagnoseCmd.PersistentFlags().StringVar(&durabilityArg, "durability", "", "durability level used by the application (none, majority, majorityAndPersistActive, persistToMajority)")
+	diagnoseCmd.PersistentFlags().StringVar(&printConfigArg, "print-config", "", "write the raw configs that were fetched to this file (- for stdout)")
+	diagnoseCmd.PersistentFlags().StringVar(&outFileArg, "out-file", "", "also write the full output, in the selected format and without colors, to this file")
+	diagnoseCmd.PersistentFlags().StringVar(&topologyDotArg, "topology-dot", "", "write a Graphviz DOT diagram of the cluster topology to this file")
+	diagnoseCmd.PersistentFlags().StringVar(&labelArg, "label", "", "label to annotate the output with, to tell several runs apart")
+	diagnoseCmd.PersistentFlags().StringVar(&formatArg, "format", doctor.DefaultFormat,
+		fmt.Sprintf("summary output format (%s)", strings.Join(doctor.Formats(), ", ")))
+	diagnoseCmd.PersistentFlags().StringVar(&groupByArg, "group-by", "phase", "how to organize the summary of the text format (phase, node)")
+	diagnoseCmd.PersistentFlags().DurationVar(&idleTestArg, "idle-test", 0, "hold an idle KV connection open for up to this long to detect idle timeouts (e.g. 10m)")
+	diagnoseCmd.PersistentFlags().DurationVar(&intervalArg, "interval", 0, "repeat the diagnosis this often and report what changed between runs, until interrupted (e.g. 1m)")
+	diagnoseCmd.PersistentFlags().DurationVar(&connectTimeoutArg, "connect-timeout", doctor.DefaultConnectTimeout, "how long establishing a connection may take")
+	diagnoseCmd.PersistentFlags().DurationVar(&timeoutArg, "timeout", doctor.DefaultTimeout, "how long a request may take overall, including connecting")
+	diagnoseCmd.PersistentFlags().DurationVar(&dnsTimeoutArg, "dns-timeout", 0, "how long each DNS lookup may take (0 for --timeout)")
+	diagnoseCmd.PersistentFlags().DurationVar(&bootstrapTimeoutArg, "bootstrap-timeout", 0, "how long each bootstrap and cluster information request may take (0 for --timeout)")
+	diagnoseCmd.PersistentFlags().DurationVar(&probeTimeoutArg, "probe-timeout", 0, "how long each request probing a node's services may take (0 for --timeout)")
+	diagnoseCmd.PersistentFlags().StringVar(&localAddrArg, "local-addr", "", "local IP address to make all connections from")
+	diagnoseCmd.PersistentFlags().StringVar(&dnsServerArg, "dns-server", "", "DNS server to perform all lookups against (host[:port])")
+	diagnoseCmd.PersistentFlags().StringVar(&socks5Arg, "socks5", "", "SOCKS5 proxy to make all connections through ([user:password@]host:port)")
+	diagnoseCmd.PersistentFlags().IntVar(&maxHostsArg, "max-hosts", 0, "maximum number of bootstrap hosts to attempt concurrently (0 for all)")
+	diagnoseCmd.PersistentFlags().BoolVar(&stdinArg, "stdin", false, "read the connection string, and optionally the username and password, from stdin")
+	diagnoseCmd.PersistentFlags().BoolVar(&promptArg, "prompt", false, "prompt for the password on the terminal, and the username if not given, instead of passing them as flags")
+	diagnoseCmd.PersistentFlags().BoolVarP(&quietArg, "quiet", "q", false, "print only the summary, and exit with 1 if warnings or 2 if errors were found")
+	diagnoseCmd.PersistentFlags().BoolVar(&suggestArg, "suggest", true, "include remediation suggestions with warnings and errors in the summary")
+	diagnoseCmd.PersistentFlags().BoolVar(&allBucketsArg, "all-buckets", false, "check that every bucket of the cluster can be opened (requires admin credentials)")
+	diagnoseCmd.PersistentFlags().BoolVar(&listEndpointsArg, "list-endpoints", false, "list every service endpoint the cluster advertises, and whether it was reachable")
+	diagnoseCmd.PersistentFlags().BoolVar(&queryNodesArg, "query-nodes", false, "check that every query node sees all of the cluster's query nodes")
+	diagnoseCmd.PersistentFlags().BoolVar(&queryKeyspacesArg, "query-keyspaces", false, "check that every query node can query system:keyspaces")
+	diagnoseCmd.PersistentFlags().BoolVar(&continueArg, "continue", false, "check the ports of the seed hosts when bootstrapping fails, instead of stopping")
+	diagnoseCmd.PersistentFlags().BoolVar(&selfTestArg, "selftest", false, "check the local environment (DNS, clock, outbound connectivity, proxies) before diagnosing the cluster")
+	diagnoseCmd.PersistentFlags().BoolVar(&redactArg, "redact", false, "replace hostnames, IP addresses and bucket names with pseudonyms, to share the output safely")
+	diagnoseCmd.PersistentFlags().BoolVar(&traceHTTPArg, "trace-http", false, "log every HTTP request and response, with credentials redacted")
+	diagnoseCmd.PersistentFlags().IntVar(&mgmtPortArg, "mgmt-port", 0, "management port to bootstrap against for hosts specified without a port (0 for the default)")
+	diagnoseCmd.PersistentFlags().IntVar(&pingCountArg, "ping-count", doctor.DefaultPingCount, "number of NOOPs sent to each KV node to measure its latency")
+	diagnoseCmd.PersistentFlags().IntVar(&maxSeedHostsArg, "max-seed-hosts", doctor.DefaultMaxSeedHosts, "number of bootstrap hosts above which the connection string is reported as listing too many")
 }
 
-var gLog helpers.Logger
-
 func runDiagnose(cmd *cobra.Command, args []string) error {
-	fmt.Printf(
-		"Note: Diagnostics can only provide accurate results when your cluster\n" +
-			" is in a stable state.  Active rebalancing and other cluster configuration\n" +
-			" changes can cause the output of the doctor to be inconsistent or in the\n" +
-			" worst cases, completely incorrect.\n")
-	fmt.Printf("\n")
-
-	var connStr string
-	if len(args) < 1 {
-		connStr = "couchbase://localhost"
-		gLog.Warn("No connection string specified, defaulting to `%s`", connStr)
-	} else {
-		connStr = args[0]
+	if !isKnownFormat(formatArg) {
+		return fmt.Errorf("unknown format `%s` (expected one of: %s)",
+			formatArg, strings.Join(doctor.Formats(), ", "))
 	}
 
-	var tlsConfig *tls.Config
-	if tlsCaArg != "" {
-		caCertData, err := ioutil.ReadFile(tlsCaArg)
-		if err != nil {
-			gLog.Error("Failed to read specified TLS certificate authority: %s", err)
-			return nil
-		}
-
-		rootCAs := x509.NewCertPool()
-		rootCAs.AppendCertsFromPEM(caCertData)
-
-		tlsConfig = &tls.Config{}
-		tlsConfig.RootCAs = rootCAs
-	}
-
-	if passwordArg == "" && bucketPasswordArg != "" {
-		passwordArg = bucketPasswordArg
-	}
-	diagnose(connStr, usernameArg, passwordArg, tlsConfig)
-
-	gLog.Log("Diagnostics completed")
-	gLog.NewLine()
-
-	gLog.PrintSummary()
-
-	return nil
-}
-
-type clusterConfigNode struct {
-	OptNode           string         `json:"optNode"`
-	ThisNode          bool           `json:"thisNode"`
-	CouchAPIBase      string         `json:"couchApiBase"`
-	CouchAPIBaseHTTPS string         `json:"couchApiBaseHTTPS"`
-	Status            string         `json:"status"`
-	Hostname          string         `json:"hostname"`
-	Version           string         `json:"version"`
-	Os                string         `json:"os"`
-	Ports             map[string]int `json:"Ports"`
-	Services          []string       `json:"services"`
-}
-
-type clusterConfig struct {
-	Nodes   []clusterConfigNode `json:"nodes"`
-	Buckets struct {
-		URI string `json:"uri"`
-	} `json:"buckets"`
-}
-
-type bucketConfigAlternateNames struct {
-	Hostname string         `json:"hostname"`
-	Ports    map[string]int `json:"ports"`
-}
-
-type bucketConfigNodeExt struct {
-	ThisNode       bool                                  `json:"thisNode"`
-	Hostname       string                                `json:"hostname"`
-	Services       map[string]int                        `json:"services"`
-	AlternateNames map[string]bucketConfigAlternateNames `json:"alternateAddresses"`
-}
-
-type terseBucketConfig struct {
-	SourceHost string
-	UUID       string                `json:"uuid"`
-	Rev        uint                  `json:"rev"`
-	NodesExt   []bucketConfigNodeExt `json:"nodesExt"`
-}
-
-func (config *terseBucketConfig) GetSourceNodeExt() *bucketConfigNodeExt {
-	for _, node := range config.NodesExt {
-		if node.ThisNode {
-			return &node
+	var connStr string
+	if stdinArg || (len(args) >= 1 && args[0] == "-") {
+		if len(args) >= 1 && args[0] != "-" {
+			return fmt.Errorf("--stdin cannot be combined with a connection string argument")
 		}
-	}
-
-	return nil
-}
-
-type clusterNode struct {
-	Hostname string
-	Services map[string]int
-}
-
-func clusterNodesFromTerseBucketConfig(config terseBucketConfig, networkType string) []clusterNode {
-	var out []clusterNode
 
-	for _, node := range config.NodesExt {
-		var newNode clusterNode
-
-		if node.Hostname == "" {
-			newNode.Hostname = config.SourceHost
-		} else {
-			newNode.Hostname = node.Hostname
+		var err error
+		connStr, err = readStdinArgs(os.Stdin)
+		if err != nil {
+			return err
 		}
-
-		newNode.Services = node.Services
-
-		if networkType != "default" {
-			netInfo, found := node.AlternateNames[networkType]
-			if !found {
-				return nil
-			}
-
-			if netInfo.Hostname != "" {
-				newNode.Hostname = netInfo.Hostname
-			}
-			if netInfo.Ports != nil {
-				newNode.Services = netInfo.Ports
-			}
+	} else if len(args) >= 1 {
+		connStr = strings.TrimSpace(args[0])
+		if connStr == "" {
+			return fmt.Errorf("connection string is empty, expected something like:\n  %s diagnose couchbase://127.0.0.1/default",
+				RootCmd.Name())
 		}
-
-		out = append(out, newNode)
 	}
 
-	return out
-}
-
-func networkFromTerseBucketConfig(config terseBucketConfig) string {
-	// Check if we connected using any of the ports associated with the default
-	// configurations that are available.
-	for _, node := range config.NodesExt {
-		for _, svcPort := range node.Services {
-			if fmt.Sprintf("%s:%d", node.Hostname, svcPort) == config.SourceHost {
-				return "default"
-			}
+	if promptArg {
+		if stdinArg || (len(args) >= 1 && args[0] == "-") {
+			return fmt.Errorf("--prompt cannot be combined with reading from stdin")
 		}
-	}
-
-	for _, node := range config.NodesExt {
-		if _, found := node.AlternateNames["external"]; found {
-			return "external"
+		if passwordArg != "" || bucketPasswordArg != "" {
+			return fmt.Errorf("--prompt cannot be combined with --password")
 		}
-	}
-
-	return "default"
-}
-
-func fetchHTTPTerseBucketConfig(host string, port int, bucket, user, pass string, tlsConfig *tls.Config) (terseBucketConfig, error) {
-	if user == "" {
-		user = bucket
-	}
-
-	httpTransport := &http.Transport{
-		TLSClientConfig: tlsConfig,
-	}
-	httpClient := &http.Client{
-		Transport: httpTransport,
-		Timeout:   2000 * time.Millisecond,
-	}
-
-	uri := fmt.Sprintf("http://%s:%d/pools/default/b/%s", host, port, bucket)
-	req, _ := http.NewRequest("GET", uri, nil)
-	req.SetBasicAuth(user, pass)
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return terseBucketConfig{}, err
-	}
 
-	if resp.StatusCode != 200 {
-		if resp.StatusCode == 401 {
-			return terseBucketConfig{}, errors.New("incorrect bucket/password")
+		if err := promptCredentials(os.Stdin, os.Stderr); err != nil {
+			return err
 		}
-
-		return terseBucketConfig{}, fmt.Errorf("http error (status code: %d)", resp.StatusCode)
-	}
-
-	configBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return terseBucketConfig{}, err
-	}
-
-	configBytes = bytes.Replace(configBytes, []byte("$HOST"), []byte(host), -1)
-
-	var config terseBucketConfig
-	err = json.Unmarshal(configBytes, &config)
-	if err != nil {
-		return terseBucketConfig{}, err
-	}
-
-	config.SourceHost = host
-
-	return config, nil
-}
-
-func fetchCccpTerseBucketConfig(host string, port int, bucket, user, pass string, tlsConfig *tls.Config) (terseBucketConfig, error) {
-	if user == "" {
-		user = bucket
-	}
-
-	client, err := helpers.Dial(host, port, bucket, user, pass, tlsConfig)
-	if err != nil {
-		return terseBucketConfig{}, err
 	}
 
-	configBytes, err := client.GetConfig()
-	if err != nil {
-		return terseBucketConfig{}, err
+	if groupByArg != "phase" && groupByArg != "node" {
+		return fmt.Errorf("unknown grouping `%s` (expected one of: phase, node)", groupByArg)
 	}
-
-	configBytes = bytes.Replace(configBytes, []byte("$HOST"), []byte(host), -1)
-
-	var config terseBucketConfig
-	err = json.Unmarshal(configBytes, &config)
-	if err != nil {
-		return terseBucketConfig{}, err
+	if groupByArg == "node" && formatArg != doctor.DefaultFormat {
+		return fmt.Errorf("--group-by node cannot be combined with --format %s", formatArg)
 	}
 
-	config.SourceHost = host
-
-	return config, nil
-}
-
-func diagnose(connStr, username, password string, tlsConfig *tls.Config) {
-	//======================================================================
-	//  CONNECTION STRING
-	//======================================================================
-	gLog.Log("Parsing connection string `%s`", connStr)
-
-	connSpec, err := gocbconnstr.Parse(connStr)
-	if err != nil {
-		gLog.Error("Failed to parse connection string of `%s` (error: %s)",
-			connStr, err.Error())
+	if intervalArg > 0 && formatArg != doctor.DefaultFormat {
+		return fmt.Errorf("--interval cannot be combined with --format %s", formatArg)
 	}
 
-	connSpecSrv := connSpec.SrvRecordName()
-	if connSpecSrv != "" {
-		gLog.Log("Connection string was parsed as a potential DNS SRV record")
+	if printConfigArg == "-" && formatArg != doctor.DefaultFormat {
+		return fmt.Errorf("--print-config - cannot be combined with --format %s, write the configs to a file instead", formatArg)
 	}
 
-	if connSpec.Scheme == "http" {
-		gLog.Warn(
-			"Connection string is using the deprecated `http://` scheme.  Use" +
-				" the `couchbase://` scheme instead!")
+	if outFileArg != "" && intervalArg > 0 {
+		return fmt.Errorf("--out-file cannot be combined with --interval")
 	}
 
-	resConnSpec, err := gocbconnstr.Resolve(connSpec)
-	if err != nil {
-		gLog.Error("Failed to properly resolve connection string `%s` (error: %s)",
-			connStr, err.Error())
+	// Keep stdout clean for machine-readable output
+	var logOut io.Writer = os.Stdout
+	summaryOut := os.Stdout
+	if formatArg != doctor.DefaultFormat {
+		logOut = os.Stderr
+	} else if printConfigArg == "-" {
+		logOut = os.Stderr
+		summaryOut = os.Stderr
 	}
-
-	if resConnSpec.UseSsl {
-		gLog.Log("Connection string specifies to use secured connections")
+	if quietArg {
+		logOut = ioutil.Discard
 	}
 
-	gLog.Log("Connection string identifies the following CCCP endpoints:")
-	for i, host := range resConnSpec.MemdHosts {
-		gLog.Log("  %d. %s:%d", i+1, host.Host, host.Port)
-	}
+	var outFile *os.File
+	if outFileArg != "" {
+		var err error
+		outFile, err = os.Create(outFileArg)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %s", err)
+		}
+		defer outFile.Close()
 
-	gLog.Log("Connection string identifies the following HTTP endpoints:")
-	for i, host := range resConnSpec.HttpHosts {
-		gLog.Log("  %d. %s:%d", i+1, host.Host, host.Port)
+		// The log is part of the full output only for the text format, the
+		//  other formats already include its entries.
+		if formatArg == doctor.DefaultFormat {
+			logOut = io.MultiWriter(logOut, outFile)
+		}
 	}
 
-	gLog.Log("Connection string specifies bucket `%s`", resConnSpec.Bucket)
-
-	//======================================================================
-	//  SSL
-	//======================================================================
-	if resConnSpec.UseSsl {
-		if tlsConfig == nil {
-			gLog.Warn("No certificate authority file specified (--tls-ca), skipping" +
-				" server certificate verification for this run.")
-
-			tlsConfig = &tls.Config{
-				InsecureSkipVerify: true,
-			}
+	var configOut io.Writer
+	if printConfigArg == "-" {
+		configOut = os.Stdout
+	} else if printConfigArg != "" {
+		configFile, err := os.Create(printConfigArg)
+		if err != nil {
+			return fmt.Errorf("failed to create config output file: %s", err)
 		}
-	} else {
-		tlsConfig = nil
-	}
+		defer configFile.Close()
 
-	//======================================================================
-	//  DNS
-	//======================================================================
-	warnSingleHost := false
-	if len(connSpec.Addresses) == 1 {
-		warnSingleHost = true
+		configOut = configFile
 	}
 
-	dnsHosts := connSpec.Addresses
-	if connSpecSrv != "" {
-		_, srvAddrs, _ := net.LookupSRV("", "", connSpecSrv)
-		aAddrs, _ := net.LookupHost(connSpec.Addresses[0].Host)
-
-		if len(srvAddrs) > 0 {
-			// Don't warn for single-hosts if using DNS SRV
-			warnSingleHost = false
-
-			// Replace the hosts for DNS testing with the values from the DNS SRV record
-			dnsHosts = []gocbconnstr.Address{}
-			for _, addr := range srvAddrs {
-				addrTarget := addr.Target
-				addrPort := int(addr.Port)
-
-				if !strings.HasSuffix(addrTarget, ".") {
-					gLog.Warn(
-						"The hostname specified in one of the SRV records was missing the trailing" +
-							" dot which is expected to make a valid SRV record entry.")
-				}
-
-				addrTarget = strings.TrimSuffix(addrTarget, ".")
-
-				dnsHosts = append(dnsHosts, gocbconnstr.Address{
-					Host: addrTarget,
-					Port: addrPort,
-				})
-			}
+	var topologyOut io.Writer
+	if topologyDotArg != "" {
+		topologyFile, err := os.Create(topologyDotArg)
+		if err != nil {
+			return fmt.Errorf("failed to create topology output file: %s", err)
 		}
+		defer topologyFile.Close()
 
-		if len(srvAddrs) > 0 && len(aAddrs) > 0 {
-			gLog.Warn(
-				"The hostname specified in your connection string resolves both for SRV" +
-					" records, as well as A records.  This is not suggested as later DNS" +
-					" configuration changes could cause the wrong servers to be contacted")
-		}
+		topologyOut = topologyFile
 	}
 
-	if warnSingleHost {
-		gLog.Warn(
-			"Your connection string specifies only a single host.  You should" +
-				" consider adding additional static nodes from your cluster to this" +
-				" list to improve your applications fault-tolerance")
+	// The banner and preamble are part of the log, so that they never end up in
+	//  machine-readable output and are left out of --quiet runs.
+	printBanner(logOut)
+	fmt.Fprintf(logOut,
+		"Note: Diagnostics can only provide accurate results when your cluster\n"+
+			" is in a stable state.  Active rebalancing and other cluster configuration\n"+
+			" changes can cause the output of the doctor to be inconsistent or in the\n"+
+			" worst cases, completely incorrect.\n")
+	if labelArg != "" {
+		fmt.Fprintf(logOut, "Label: %s\n", labelArg)
 	}
+	fmt.Fprintf(logOut, "\n")
 
-	for _, target := range dnsHosts {
-		strippedHost := stripIPv6Address(target.Host)
-
-		gLog.Log("Performing DNS lookup for host `%s`", strippedHost)
-
-		addrs, err := net.LookupHost(strippedHost)
-
+	var tlsConfig *tls.Config
+	if tlsCaArg != "" {
+		caCertData, err := ioutil.ReadFile(tlsCaArg)
 		if err != nil {
-			if dnsErr, ok := err.(*net.DNSError); ok {
-				if dnsErr.Err == "no such host" {
-					err = nil
-					addrs = nil
-				}
-			} else {
-				gLog.Error(
-					"Failed to perform DNS lookup for bootstrap entry `%s` (error: %s)",
-					err)
-				continue
-			}
+			return fmt.Errorf("failed to read specified TLS certificate authority: %s", err)
 		}
 
-		if err != nil || len(addrs) == 0 {
-			gLog.Error(
-				"Bootstrap host `%s` does not have a valid DNS entry.",
-				strippedHost)
-			continue
-		} else if len(addrs) > 1 {
-			gLog.Warn(
-				"Bootstrap host `%s` has more than one single DNS entry associated.  While this"+
-					" is not neccessarily an error, it has been known to cause difficult-to-diagnose"+
-					" problems in the future when routing is changed or the cluster layout is updated.",
-				strippedHost)
-		} else if addrs[0] != strippedHost {
-			gLog.Log(
-				"Bootstrap host `%s` refers to a server with the address `%s`",
-				strippedHost, addrs[0])
-		}
+		rootCAs := x509.NewCertPool()
+		rootCAs.AppendCertsFromPEM(caCertData)
 
-		// Check for any IPv6 addresses
-		ips, _ := net.LookupIP(strippedHost)
+		tlsConfig = &tls.Config{}
+		tlsConfig.RootCAs = rootCAs
+	}
 
-		hasIPv6 := false
-		for _, ip := range ips {
-			if ip.To4() == nil {
-				hasIPv6 = true
-			}
-		}
-		if hasIPv6 {
-			gLog.Log(
-				"Bootstrap host `%s` has IPv6 addresses associated. This is only supported"+
-					" in Couchbase Server 5.5 or later, and must be specifically enabled on"+
-					" the cluster.",
-				strippedHost)
-		}
+	if passwordArg == "" && bucketPasswordArg != "" {
+		passwordArg = bucketPasswordArg
 	}
 
-	//======================================================================
-	//  BOOTSTRAP
-	//======================================================================
-	var nodesList []clusterNode
-	var selectedNetwork string
-	var configSource string
-
-	// Scans a list of hosts and configurations and logs any appropriate warnings then returns
-	//  the first good configuration that it actually encounters (or nil if none are found).
-	scanTerseConfigList := func(hosts []gocbconnstr.Address, configs []*terseBucketConfig) *terseBucketConfig {
-		if len(hosts) != len(configs) {
-			panic(0)
+	// Stop diagnosing on Ctrl-C, but still print what was found so far.  Further
+	//  interrupts are left to kill the process as usual.  The diagnosis may still
+	//  be logging, so the interruption is only reported once it has stopped.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupts:
+			signal.Stop(interrupts)
+			cancel()
+		case <-ctx.Done():
 		}
-
-		var masterConfig *terseBucketConfig
-
-		for i, target := range hosts {
-			config := configs[i]
-
-			if config == nil {
-				continue
-			}
-
-			if masterConfig == nil {
-				masterConfig = config
-			} else {
-				if config.UUID != masterConfig.UUID {
-					gLog.Error(
-						"Boostrap host `%s` appears to be pointing to a different cluster.  Tests"+
-							" will be running against the first successfully connected node in your"+
-							" bootstrap list, as a client would behave.",
-						target.Host)
-				}
-			}
-
-			thisNodeExt := config.GetSourceNodeExt()
-			if thisNodeExt.Hostname != "" && target.Host != thisNodeExt.Hostname {
-				gLog.Warn(
-					"Bootstrap host `%s` is not using the canonical node hostname of `%s`.  This"+
-						" is not neccessarily an error, but has been known to result in strange and"+
-						" challenging to diagnose errors when DNS entries are reconfigured.",
-					target.Host, thisNodeExt.Hostname)
-			}
+	}()
+	defer signal.Stop(interrupts)
+
+	opts := doctor.Options{
+		Label:            labelArg,
+		ConnStr:          connStr,
+		Username:         usernameArg,
+		Password:         passwordArg,
+		Bucket:           bucketArg,
+		SDK:              sdkArg,
+		ExpectUUID:       expectUUIDArg,
+		Scope:            scopeArg,
+		Collection:       collectionArg,
+		Durability:       durabilityArg,
+		TLSConfig:        tlsConfig,
+		TLSServerName:    tlsSNIArg,
+		IdleTest:         idleTestArg,
+		LocalAddr:        localAddrArg,
+		MaxHosts:         maxHostsArg,
+		MaxSeedHosts:     maxSeedHostsArg,
+		PingCount:        pingCountArg,
+		MgmtPort:         mgmtPortArg,
+		Continue:         continueArg,
+		QueryNodes:       queryNodesArg,
+		QueryKeyspaces:   queryKeyspacesArg,
+		ListEndpoints:    listEndpointsArg,
+		AllBuckets:       allBucketsArg,
+		ConnectTimeout:   connectTimeoutArg,
+		Timeout:          timeoutArg,
+		DNSTimeout:       dnsTimeoutArg,
+		BootstrapTimeout: bootstrapTimeoutArg,
+		ProbeTimeout:     probeTimeoutArg,
+		SelfTest:         selfTestArg,
+		TraceHTTP:        traceHTTPArg,
+		Redact:           redactArg,
+		SOCKS5:           socks5Arg,
+		DNSServer:        dnsServerArg,
+		NoSuggestions:    !suggestArg,
+		ConfigOutput:     configOut,
+		TopologyOutput:   topologyOut,
+		Output:           logOut,
+	}
+
+	if intervalArg > 0 {
+		return runMonitor(ctx, opts, summaryOut)
+	}
+
+	// Errors are already part of the report, so there's nothing more to do with them here.
+	report, _ := doctor.RunContext(ctx, opts)
+	if ctx.Err() != nil {
+		fmt.Fprintf(logOut, "\nInterrupted, stopped diagnostics early\n")
+	}
+
+	writeSummary := func(w io.Writer) error {
+		if groupByArg == "node" {
+			report.PrintSummaryByNode(w)
+			return nil
 		}
-
-		return masterConfig
+		return report.WriteFormatted(w, formatArg)
 	}
 
-	// Attempt to bootstrap via CCCP
-	if nodesList == nil {
-		if len(resConnSpec.MemdHosts) == 0 {
-			gLog.Log("Not attempting CCCP, as the connection string does not support it")
-		} else {
-			gLog.Log("Attempting to connect to cluster via CCCP")
-
-			configs := make([]*terseBucketConfig, len(resConnSpec.MemdHosts))
-
-			for i, target := range resConnSpec.MemdHosts {
-				gLog.Log("Attempting to fetch config via cccp from `%s:%d`", target.Host, target.Port)
-
-				// Query the host
-				config, err := fetchCccpTerseBucketConfig(target.Host, target.Port, resConnSpec.Bucket, username, password, tlsConfig)
-				if err != nil {
-					gLog.Error(
-						"Failed to fetch configuration via cccp from `%s:%d` (error: %s)",
-						target.Host, target.Port, err.Error())
-
-					continue
-				}
-
-				configs[i] = &config
-			}
-
-			masterConfig := scanTerseConfigList(resConnSpec.MemdHosts, configs)
-			if masterConfig != nil {
-				if selectedNetwork == "" {
-					selectedNetwork = networkFromTerseBucketConfig(*masterConfig)
-				}
-				nodesList = clusterNodesFromTerseBucketConfig(*masterConfig, selectedNetwork)
-				configSource = "cccp"
-			}
-		}
+	fmt.Fprintf(logOut, "\n")
+	if err := writeSummary(summaryOut); err != nil {
+		return err
 	}
 
-	// Attempt to bootstrap via Terse HTTP endpoints
-	if nodesList == nil {
-		if len(resConnSpec.HttpHosts) == 0 {
-			gLog.Log("Not attempting HTTP (Terse), as the connection string does not support it")
-		} else {
-			gLog.Log("Attempting to connect to cluster via HTTP (Terse)")
-
-			configs := make([]*terseBucketConfig, len(resConnSpec.HttpHosts))
-
-			for i, target := range resConnSpec.HttpHosts {
-				gLog.Log("Attempting to fetch terse config via http from `%s:%d`", target.Host, target.Port)
-
-				// Query the host
-				config, err := fetchHTTPTerseBucketConfig(target.Host, target.Port, resConnSpec.Bucket, username, password, tlsConfig)
-				if err != nil {
-					gLog.Error(
-						"Failed to fetch terse configuration via http from `%s:%d` (error: %s)",
-						target.Host, target.Port, err.Error())
-
-					continue
-				}
-
-				configs[i] = &config
-			}
-
-			masterConfig := scanTerseConfigList(resConnSpec.HttpHosts, configs)
-			if masterConfig != nil {
-				if selectedNetwork == "" {
-					selectedNetwork = networkFromTerseBucketConfig(*masterConfig)
-				}
-				nodesList = clusterNodesFromTerseBucketConfig(*masterConfig, selectedNetwork)
-				configSource = "http-terse"
-			}
+	if outFile != nil {
+		// The file is meant to be attached to tickets, so it never holds color codes
+		noColor := color.NoColor
+		color.NoColor = true
+		err := writeSummary(outFile)
+		color.NoColor = noColor
+		if err != nil {
+			return fmt.Errorf("failed to write output file: %s", err)
 		}
 	}
 
-	// Attempt to bootstrap via full HTTP endpoints
-	if nodesList == nil {
-		if len(resConnSpec.HttpHosts) == 0 {
-			gLog.Log("Not attempting HTTP (Full), as the connection string does not support it")
-		} else {
-			gLog.Log("Attempting to connect to cluster via HTTP (Full)")
-
-			// TODO: Add support for full HTTP configuration fetching
-
-			gLog.Log("Failed to connect via HTTP (Full), as it is not yet supported by the doctor")
+	if quietArg {
+		if len(report.Errors()) > 0 {
+			exitCode = 2
+		} else if len(report.Warnings()) > 0 {
+			exitCode = 1
 		}
 	}
+	return nil
+}
 
-	// Print out information about which network type was selected
-	gLog.Log("Selected the following network type: %s", selectedNetwork)
-
-	// Failed to bootstrap
-	if nodesList == nil {
-		gLog.Error(
-			"All endpoints specified by your connection string were unreachable, further" +
-				" cluster diagnostics are not possible")
-		return
-	}
-
-	gLog.Log("Identified the following nodes:")
-	for i, target := range nodesList {
-		gLog.Log("  [%d] %s", i, target.Hostname)
-
-		serviceStr := ""
-		serviceNum := 0
-		for service, port := range target.Services {
-			if serviceStr != "" {
-				serviceStr += ", "
-			}
+// runMonitor repeats the diagnosis every --interval until ctx is cancelled.  The
+// first run is reported in full, later runs only report what changed, and the
+// findings of every run are aggregated once interrupted.
+func runMonitor(ctx context.Context, opts doctor.Options, out io.Writer) error {
+	var history doctor.History
+	var previous doctor.Report
 
-			serviceStr += fmt.Sprintf("%20s:% 6d", service, port)
+	for {
+		report, _ := doctor.RunContext(ctx, opts)
 
-			if serviceNum%3 == 2 {
-				gLog.Log("    %s", serviceStr)
-				serviceStr = ""
+		if history.Runs() == 0 {
+			fmt.Fprintf(opts.Output, "\n")
+			if err := report.WriteFormatted(out, doctor.DefaultFormat); err != nil {
+				return err
 			}
-
-			serviceNum++
+			fmt.Fprintf(out, "\nRepeating every %s, press Ctrl-C to stop and print an aggregate.\n", intervalArg)
+
+			// Only the first run is logged and writes its configs and topology
+			opts.Output = nil
+			opts.ConfigOutput = nil
+			opts.TopologyOutput = nil
+		} else if !report.Interrupted {
+			report.Delta(previous).PrintDelta(out, report.Started)
 		}
 
-		if serviceStr != "" {
-			gLog.Log("    %s", serviceStr)
+		// A run cut short would appear to have resolved whatever it did not reach
+		if !report.Interrupted || history.Runs() == 0 {
+			history.Add(report)
+			previous = report
 		}
-	}
-
-	if configSource != "cccp" {
-		gLog.Warn(
-			"Your configuration was fetched via a non-optimal path, you should update your" +
-				" connection string and/or cluster configuration to allow CCCP config fetch")
-	}
 
-	//======================================================================
-	//  CLUSTER INFORMATION
-	//======================================================================
-	{
-		var infoSourceTarget *clusterNode
-
-		infoSourceSvcKey := "mgmt"
-		infoSourceScheme := "http"
-		if tlsConfig != nil {
-			infoSourceSvcKey = "mgmtSSL"
-			infoSourceScheme = "https"
+		timer := time.NewTimer(intervalArg)
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
 		}
+		timer.Stop()
 
-		for _, target := range nodesList {
-			if target.Services[infoSourceSvcKey] != 0 {
-				infoSourceTarget = &target
-				break
-			}
+		if ctx.Err() != nil {
+			break
 		}
+	}
 
-		if infoSourceTarget == nil {
-			gLog.Log("Failed to retrieve cluster information as we couldn't find a node with management services")
-		} else {
-			infoSourceHost := infoSourceTarget.Hostname
-			infoSourcePort := infoSourceTarget.Services[infoSourceSvcKey]
-
-			gLog.Log("Fetching config from `%s://%s:%d`",
-				infoSourceScheme,
-				infoSourceHost,
-				infoSourcePort)
-
-			httpTransport := &http.Transport{
-				TLSClientConfig: tlsConfig,
-			}
-			httpClient := &http.Client{
-				Transport: httpTransport,
-				Timeout:   2000 * time.Millisecond,
-			}
+	// The loop only ends once interrupted
+	fmt.Fprintf(out, "\nInterrupted, stopped repeating diagnostics\n\n")
+	history.PrintSummary(out)
 
-			uri := fmt.Sprintf("%s://%s:%d/pools/default", infoSourceScheme, infoSourceHost, infoSourcePort)
-			req, _ := http.NewRequest("GET", uri, nil)
-			req.SetBasicAuth(username, password)
-
-			resp, err := httpClient.Do(req)
-			if err != nil {
-				gLog.Log("Failed to retreive cluster information (error: %s)", err.Error())
-			} else if resp.StatusCode != 200 {
-				gLog.Log("Failed to retreive cluster information (status code: %d)", resp.StatusCode)
-			} else {
-				var clusterConfig map[string]interface{}
-				json.NewDecoder(resp.Body).Decode(&clusterConfig)
-
-				fmtdConfigNodes, _ := json.MarshalIndent(clusterConfig["nodes"], "", "  ")
-				gLog.Log("Received cluster configuration, nodes list:\n%s", fmtdConfigNodes)
-			}
+	if quietArg {
+		if history.HasErrors() {
+			exitCode = 2
+		} else if history.HasWarnings() {
+			exitCode = 1
 		}
 	}
+	return nil
+}
 
-	//======================================================================
-	//  SERVICES
-	//======================================================================
-
-	testHTTPTransport := &http.Transport{
-		TLSClientConfig: tlsConfig,
+// readStdinArgs reads the connection string from the first line of r, followed
+// by optional username and password lines which fill in any missing flags.
+func readStdinArgs(r io.Reader) (string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for len(lines) < 3 && scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
 	}
-	testHTTPClient := &http.Client{
-		Transport: testHTTPTransport,
-		Timeout:   2000 * time.Millisecond,
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read from stdin: %s", err)
 	}
 
-	testMemdService := func(node clusterNode, svcName, svcKeyPlain, svcKeySSL string) {
-		svcKey := svcKeyPlain
-		if tlsConfig != nil {
-			svcKey = svcKeySSL
-		}
-
-		svcPort := node.Services[svcKey]
-		if svcPort != 0 {
-			client, err := helpers.Dial(node.Hostname, svcPort,
-				resConnSpec.Bucket, username, password, tlsConfig)
-			if err != nil {
-				gLog.Error("Failed to connect to %s service at `%s:%d` (error: %s)",
-					svcName, node.Hostname, node.Services[svcKey], err.Error())
-			} else {
-				gLog.Log("Successfully connected to %s service at `%s:%d`",
-					svcName, node.Hostname, node.Services[svcKey])
-
-				client.Close()
-			}
-		} else {
-			gLog.Warn("Could not test %s service on `%s` as it was not in the config", svcName, node.Hostname)
-		}
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) == "" {
+		return "", fmt.Errorf("no connection string was read from stdin")
 	}
 
-	testHTTPService := func(node clusterNode, svcName, svcKeyPlain, svcKeySSL string) {
-		svcScheme := "http"
-		svcKey := svcKeyPlain
-		if tlsConfig != nil {
-			svcScheme = "https"
-			svcKey = svcKeySSL
-		}
-
-		svcPort := node.Services[svcKey]
-		if svcPort != 0 {
-			uri := fmt.Sprintf("%s://%s:%d/", svcScheme, node.Hostname, svcPort)
-			req, _ := http.NewRequest("GET", uri, nil)
-			// No credentials are set here since we only care that the service responds,
-			//  not that it responds with anything in particular.
-
-			_, err := testHTTPClient.Do(req)
-			if err != nil {
-				gLog.Error("Failed to connect to %s service at `%s:%d` (error: %s)",
-					svcName, node.Hostname, node.Services[svcKey], err.Error())
-			} else {
-				gLog.Log("Successfully connected to %s service at `%s:%d`",
-					svcName, node.Hostname, node.Services[svcKey])
-			}
-		} else {
-			gLog.Warn("Could not test %s service on `%s` as it was not in the config", svcName, node.Hostname)
-		}
+	if len(lines) >= 2 && usernameArg == "" {
+		usernameArg = strings.TrimSpace(lines[1])
 	}
-
-	for _, node := range nodesList {
-		testMemdService(node, "Key Value", "kv", "kvSSL")
-		testHTTPService(node, "Management", "mgmt", "mgmtSSL")
-		testHTTPService(node, "Views", "capi", "capiSSL")
-		testHTTPService(node, "Query", "n1ql", "n1qlSSL")
-		testHTTPService(node, "Search", "fts", "ftsSSL")
-		testHTTPService(node, "Analytics", "cbas", "cbasSSL")
+	if len(lines) >= 3 && passwordArg == "" {
+		passwordArg = lines[2]
 	}
 
-	//======================================================================
-	//  CONNECTION PERFORMANCE
-	//======================================================================
-	for _, node := range nodesList {
-		kvPort := node.Services["kv"]
-		if tlsConfig != nil {
-			kvPort = node.Services["kvSSL"]
-		}
-
-		if kvPort != 0 {
-			client, err := helpers.Dial(node.Hostname, kvPort,
-				resConnSpec.Bucket, username, password, tlsConfig)
-			if err != nil {
-				gLog.Warn(
-					"Failed to perform KV connection performance analysis on `%s:%d` (error: %d)",
-					node.Hostname, kvPort, err.Error())
-				continue
-			}
-
-			var stats helpers.PingHelper
-
-			for i := 0; i < 10; i++ {
-				pingState := stats.StartOne()
-				err = client.Ping()
-				stats.StopOne(pingState, err)
-			}
-
-			gLog.Log("Memd Nop Pinged `%s:%d` %d times, %d errors, %dms min, %dms max, %dms mean",
-				node.Hostname, kvPort,
-				stats.Count(), stats.Errors(),
-				stats.Min()/time.Millisecond,
-				stats.Max()/time.Millisecond,
-				stats.Mean()/time.Millisecond)
-
-			allowedMeanMs := 10
-			if stats.Mean() >= time.Duration(allowedMeanMs)*time.Millisecond {
-				gLog.Warn(
-					"Memcached service on `%s:%d` on average took longer than %dms (was: %dms) to"+
-						" reply.  This is usually due to network-related issues, and could significantly"+
-						" affect application performance.",
-					node.Hostname, kvPort,
-					allowedMeanMs, stats.Mean()/time.Millisecond)
-			}
+	return strings.TrimSpace(lines[0]), nil
+}
 
-			allowedMaxMs := 20
-			if stats.Max() >= time.Duration(allowedMaxMs)*time.Millisecond {
-				gLog.Warn(
-					"Memcached service on `%s:%d` maximally took longer than %dms (was: %dms) to reply."+
-						" This is usually due to network-related issues, and could significantly"+
-						" affect application performance.",
-					node.Hostname, kvPort,
-					allowedMaxMs, stats.Max()/time.Millisecond)
-			}
+func isKnownFormat(format string) bool {
+	for _, name := range doctor.Formats() {
+		if name == format {
+			return true
 		}
 	}
+	return false
 }
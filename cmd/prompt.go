@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// promptCredentials asks on the terminal for the username, if none was given,
+// and for the password without echoing it
+func promptCredentials(in *os.File, out io.Writer) error {
+	if !term.IsTerminal(int(in.Fd())) {
+		return fmt.Errorf("--prompt requires an interactive terminal")
+	}
+
+	if usernameArg == "" {
+		fmt.Fprintf(out, "Username: ")
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read username: %s", err)
+		}
+		usernameArg = strings.TrimSpace(line)
+	}
+
+	fmt.Fprintf(out, "Password: ")
+	password, err := term.ReadPassword(int(in.Fd()))
+	fmt.Fprintf(out, "\n")
+	if err != nil {
+		return fmt.Errorf("failed to read password: %s", err)
+	}
+	passwordArg = string(password)
+
+	return nil
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -10,6 +11,9 @@ import (
 
 var cfgFile string
 
+// exitCode is the status the process exits with once the command succeeded
+var exitCode int
+
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
 	Use:   "sdk-doctor",
@@ -26,6 +30,19 @@ func Execute() {
 		fmt.Println(err)
 		os.Exit(-1)
 	}
+	os.Exit(exitCode)
+}
+
+// printBanner writes the doctor's banner to w
+func printBanner(w io.Writer) {
+	fmt.Fprintf(w, "|====================================================================|\n")
+	fmt.Fprintf(w, "|          ___ ___  _  __   ___   ___   ___ _____ ___  ___           |\n")
+	fmt.Fprintf(w, "|         / __|   \\| |/ /__|   \\ / _ \\ / __|_   _/ _ \\| _ \\          |\n")
+	fmt.Fprintf(w, "|         \\__ \\ |) | ' <___| |) | (_) | (__  | || (_) |   /          |\n")
+	fmt.Fprintf(w, "|         |___/___/|_|\\_\\  |___/ \\___/ \\___| |_| \\___/|_|_\\          |\n")
+	fmt.Fprintf(w, "|                                                                    |\n")
+	fmt.Fprintf(w, "|====================================================================|\n")
+	fmt.Fprintf(w, "\n")
 }
 
 func init() {
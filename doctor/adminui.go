@@ -0,0 +1,56 @@
+package doctor
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// adminUIPath is where the management service redirects requests for `/`
+const adminUIPath = "/ui/index.html"
+
+// isCouchbaseAdminUI returns whether a response to `/` on the management port
+// came from Couchbase's admin UI, which either redirects to its index page or
+// identifies itself in the Server header
+func isCouchbaseAdminUI(resp *http.Response) bool {
+	if strings.Contains(resp.Header.Get("Server"), "Couchbase") {
+		return true
+	}
+
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return false
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(location.Path, adminUIPath)
+}
+
+// checkAdminUI warns when the response to `/` on a node's management port
+// does not look like Couchbase's admin UI, which means that some other service
+// is listening on the port, or a proxy answered in the cluster's place.  It
+// returns whether the response came from the admin UI.
+func (d *diagnoser) checkAdminUI(resp *http.Response, host string, port int) bool {
+	if isCouchbaseAdminUI(resp) {
+		return true
+	}
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		location = "none"
+	}
+	server := resp.Header.Get("Server")
+	if server == "" {
+		server = "none"
+	}
+
+	d.warnf(findingMgmtNotCouchbase,
+		"Management service at `%s:%d` responded, but not like Couchbase's admin UI (status: %d,"+
+			" Location header: `%s`, Server header: `%s`).  Another service may be listening on the"+
+			" port, or a proxy may be answering in the cluster's place, so management requests from"+
+			" SDKs are unlikely to reach the cluster.",
+		host, port, resp.StatusCode, location, server)
+	return false
+}
@@ -0,0 +1,65 @@
+package doctor
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// alpnProtocols are offered when checking which HTTP version a TLS endpoint
+// negotiates, in order of preference
+var alpnProtocols = []string{"h2", "http/1.1"}
+
+// negotiatedProtocol completes a TLS handshake with host:port offering HTTP/2
+// and HTTP/1.1, and returns the protocol the server selected via ALPN, which is
+// empty if the server does not support ALPN at all
+func (d *diagnoser) negotiatedProtocol(host string, port int) (string, error) {
+	conn, err := d.dialer.Dial("tcp", helpers.JoinHostPort(host, port))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	tlsConfig := d.tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = helpers.StripIPv6Brackets(host)
+	}
+	tlsConfig.NextProtos = alpnProtocols
+
+	conn.SetDeadline(time.Now().Add(d.timeout))
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return "", err
+	}
+
+	return tlsConn.ConnectionState().NegotiatedProtocol, nil
+}
+
+// reportHTTPVersion logs the HTTP version the secured management endpoint at
+// host:port negotiates, and notes when a proxy in front of the cluster may be
+// downgrading HTTP/2 to HTTP/1.1
+func (d *diagnoser) reportHTTPVersion(host string, port int) {
+	protocol, err := d.negotiatedProtocol(host, port)
+	if err != nil {
+		d.log.Log("Could not determine the HTTP version negotiated by `%s:%d` (error: %s)",
+			host, port, err.Error())
+		return
+	}
+
+	if protocol == "h2" {
+		d.log.Log("Management service at `%s:%d` negotiated HTTP/2", host, port)
+		return
+	}
+
+	if protocol == "" {
+		protocol = "none"
+	}
+	d.log.Log("Management service at `%s:%d` negotiated HTTP/1.1 (ALPN: %s)", host, port, protocol)
+
+	if len(d.proxySignals) > 0 {
+		d.log.Detail("Management service at `%s:%d` negotiated HTTP/1.1 while a proxy appears to be in"+
+			" front of the cluster, which may be downgrading HTTP/2.  SDKs which prefer HTTP/2 fall"+
+			" back to HTTP/1.1 for management requests against it.", host, port)
+	}
+}
@@ -0,0 +1,268 @@
+package doctor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/couchbaselabs/gocbconnstr"
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// bootstrapAttempt holds the outcome of fetching a config from a single bootstrap host
+type bootstrapAttempt struct {
+	Config *terseBucketConfig
+	Err    error
+
+	// Order is the position in which the attempt completed, starting from 0
+	Order int
+}
+
+// bootstrapHosts applies the --max-hosts limit to a list of bootstrap hosts
+func (d *diagnoser) bootstrapHosts(hosts []gocbconnstr.Address) []gocbconnstr.Address {
+	if d.opts.MaxHosts <= 0 || len(hosts) <= d.opts.MaxHosts {
+		return hosts
+	}
+
+	d.log.Log("Only attempting the first %d of %d bootstrap hosts (--max-hosts)", d.opts.MaxHosts, len(hosts))
+	return hosts[:d.opts.MaxHosts]
+}
+
+// fetchConfigs races config fetches against all hosts, the same way SDKs race
+// their bootstrap endpoints, and returns the attempts in the order of hosts.
+func (d *diagnoser) fetchConfigs(hosts []gocbconnstr.Address,
+	fetch func(host string, port int) (terseBucketConfig, error)) []bootstrapAttempt {
+	attempts := make([]bootstrapAttempt, len(hosts))
+
+	var lock sync.Mutex
+	var waitGroup sync.WaitGroup
+	numCompleted := 0
+
+	for i, target := range hosts {
+		waitGroup.Add(1)
+		go func(i int, target gocbconnstr.Address) {
+			defer waitGroup.Done()
+
+			config, err := fetch(target.Host, target.Port)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			attempts[i].Err = err
+			if err == nil {
+				attempts[i].Config = &config
+			}
+			attempts[i].Order = numCompleted
+			numCompleted++
+		}(i, target)
+	}
+
+	waitGroup.Wait()
+
+	return attempts
+}
+
+// firstSuccessfulAttempt returns the index of the successful attempt which
+// completed first, or -1 if none succeeded.
+func firstSuccessfulAttempt(attempts []bootstrapAttempt) int {
+	first := -1
+	for i, attempt := range attempts {
+		if attempt.Config == nil {
+			continue
+		}
+
+		if first == -1 || attempt.Order < attempts[first].Order {
+			first = i
+		}
+	}
+	return first
+}
+
+// revDivergenceThreshold is the spread of config revisions across bootstrap
+// hosts above which their configs are considered to diverge.  Revisions are
+// bumped by every config change, so a few apart just means a change landed
+// while the hosts were being fetched from.
+const revDivergenceThreshold = 50
+
+// configRevSpread returns the lowest and highest revision of the configs the
+// attempts fetched, along with the index of the attempt each came from.  The
+// indices are -1 if fewer than two configs were fetched.
+func configRevSpread(attempts []bootstrapAttempt) (minIdx, maxIdx int) {
+	minIdx, maxIdx = -1, -1
+	fetched := 0
+	for i, attempt := range attempts {
+		if attempt.Config == nil {
+			continue
+		}
+		fetched++
+
+		if minIdx == -1 || attempt.Config.Rev < attempts[minIdx].Config.Rev {
+			minIdx = i
+		}
+		if maxIdx == -1 || attempt.Config.Rev > attempts[maxIdx].Config.Rev {
+			maxIdx = i
+		}
+	}
+
+	if fetched < 2 {
+		return -1, -1
+	}
+	return minIdx, maxIdx
+}
+
+// bootstrapFailure records why fetching a config from a bootstrap host failed
+type bootstrapFailure struct {
+	Method string
+	Host   string
+	Port   int
+	Err    error
+}
+
+// classifyBootstrapError returns a short description of the root cause of err
+func classifyBootstrapError(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var redirectErr *redirectError
+	var opErr *net.OpError
+	var notCouchbaseErr *notCouchbaseError
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return "DNS lookup failed"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return "no route to host"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection reset"
+	case errors.As(err, &netErr) && netErr.Timeout() && errors.As(err, &opErr) && opErr.Op == "dial":
+		return "timed out connecting"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timed out waiting for a response"
+	case errors.Is(err, helpers.ErrAuthFailed), errors.Is(err, errIncorrectCredentials):
+		return "authentication failed"
+	case errors.Is(err, errConfigTruncated):
+		return "connection dropped while reading config"
+	case errors.As(err, &notCouchbaseErr):
+		return "not a Couchbase cluster"
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return "invalid configuration JSON"
+	case errors.Is(err, errConfigUnexpected):
+		return "unexpected configuration layout"
+	case errors.As(err, &redirectErr):
+		return "redirected"
+	}
+	return "failed"
+}
+
+// connectErrorHint explains what a failure to connect says about the network
+// between the doctor and the server, or returns an empty string if it says
+// nothing in particular.  Firewalls which reject and which drop connections
+// fail differently, which tells network teams which rule to look for.
+func connectErrorHint(err error) string {
+	switch classifyBootstrapError(err) {
+	case "connection refused":
+		return "The connection was actively refused, so either nothing is listening on this port or" +
+			" a firewall rejects connections to it."
+	case "no route to host":
+		return "There is no route to this host, so either the host is down or a router or firewall" +
+			" on the way rejects traffic to it."
+	case "timed out connecting":
+		return "The connection attempt went unanswered, which usually means a firewall silently" +
+			" drops connections to this port."
+	case "connection reset":
+		return "The connection was reset after being accepted, which usually means a firewall or" +
+			" proxy on the way terminates it."
+	}
+	return ""
+}
+
+// formatBootstrapFailures lists the root cause of every failed bootstrap attempt, one per line
+func formatBootstrapFailures(failures []bootstrapFailure) string {
+	var lines []string
+	for _, failure := range failures {
+		lines = append(lines, fmt.Sprintf("  %s `%s`: %s (error: %s)",
+			failure.Method, helpers.JoinHostPort(failure.Host, failure.Port),
+			classifyBootstrapError(failure.Err), failure.Err.Error()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hostsMissingFrom returns the addresses in hosts whose host does not appear in other
+func hostsMissingFrom(hosts, other []gocbconnstr.Address) []string {
+	otherHosts := make(map[string]bool)
+	for _, address := range other {
+		otherHosts[address.Host] = true
+	}
+
+	var out []string
+	for _, address := range hosts {
+		if !otherHosts[address.Host] {
+			out = append(out, fmt.Sprintf("%s:%d", address.Host, address.Port))
+		}
+	}
+	return out
+}
+
+// seedHostNames returns the distinct hosts listed by a resolved connection string
+func seedHostNames(spec gocbconnstr.ResolvedConnSpec) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, address := range append(spec.MemdHosts, spec.HttpHosts...) {
+		host := helpers.StripIPv6Brackets(address.Host)
+		if !seen[host] {
+			seen[host] = true
+			out = append(out, host)
+		}
+	}
+	return out
+}
+
+// canonicalHostNames returns the distinct hostnames the cluster advertises
+func canonicalHostNames(nodes []clusterNode) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, node := range nodes {
+		host := helpers.StripIPv6Brackets(node.Hostname)
+		if !seen[host] {
+			seen[host] = true
+			out = append(out, host)
+		}
+	}
+	return out
+}
+
+// anyHostIn returns whether any of hosts appears in other
+func anyHostIn(hosts, other []string) bool {
+	for _, host := range hosts {
+		for _, otherHost := range other {
+			if host == otherHost {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allIPs returns whether every host is an IP address literal
+func allIPs(hosts []string) bool {
+	for _, host := range hosts {
+		if net.ParseIP(host) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func formatHostList(hosts []string) string {
+	if len(hosts) == 0 {
+		return "none"
+	}
+	return "`" + strings.Join(hosts, "`, `") + "`"
+}
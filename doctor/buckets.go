@@ -0,0 +1,112 @@
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// errBucketListForbidden is returned when the credentials may not list buckets
+var errBucketListForbidden = errors.New("listing buckets requires administrator credentials")
+
+func (d *diagnoser) fetchBucketNames(scheme, host string, port int) ([]string, error) {
+	uri := fmt.Sprintf("%s://%s/pools/default/buckets", scheme, helpers.JoinHostPort(host, port))
+	req, _ := http.NewRequest("GET", uri, nil)
+	req.SetBasicAuth(d.opts.Username, d.opts.Password)
+
+	resp, _, err := d.doHTTP(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeResponse(resp)
+
+	if resp.StatusCode == 401 || resp.StatusCode == 403 {
+		return nil, errBucketListForbidden
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("http error (status code: %d)", resp.StatusCode)
+	}
+
+	bucketsBytes, err := readConfigBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []bucketConfig
+	err = unmarshalConfig(bucketsBytes, &buckets)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(buckets))
+	for _, bucket := range buckets {
+		names = append(names, bucket.Name)
+		d.redactBucket(bucket.Name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// checkAllBuckets confirms that every bucket of the cluster can be reached over
+// both KV and HTTP with the provided credentials
+func (d *diagnoser) checkAllBuckets(nodes []clusterNode, mgmtScheme string, mgmtNode *clusterNode, mgmtSvcKey string) {
+	if mgmtNode == nil {
+		d.warnf(findingBucketListFailed,
+			"Could not list the cluster's buckets as no node advertises the management service")
+		return
+	}
+
+	mgmtPort := mgmtNode.Services[mgmtSvcKey]
+	names, err := d.fetchBucketNames(mgmtScheme, mgmtNode.Hostname, mgmtPort)
+	if err != nil {
+		d.warnf(findingBucketListFailed, "Failed to list the cluster's buckets from `%s:%d` (error: %s)",
+			mgmtNode.Hostname, mgmtPort, err.Error())
+		return
+	}
+
+	d.log.Log("Cluster has %d buckets: %s", len(names), formatHostList(names))
+
+	kvSvcKey := "kv"
+	if d.tlsConfig != nil {
+		kvSvcKey = "kvSSL"
+	}
+
+	var kvNode *clusterNode
+	for i := range nodes {
+		if nodes[i].Services[kvSvcKey] != 0 {
+			kvNode = &nodes[i]
+			break
+		}
+	}
+
+	for _, name := range names {
+		kvResult := "not tested"
+		if kvNode != nil {
+			kvPort := kvNode.Services[kvSvcKey]
+			client, err := d.dialMemd(kvNode.Hostname, kvPort, name)
+			if err != nil {
+				kvResult = classifyBootstrapError(err)
+				d.warnf(findingBucketOpenFailed, "Failed to open bucket `%s` over KV at `%s:%d` (error: %s)",
+					name, kvNode.Hostname, kvPort, err.Error())
+			} else {
+				kvResult = "ok"
+				client.Close()
+			}
+		}
+
+		httpResult := "ok"
+		_, err := d.fetchHTTPTerseBucketConfig(mgmtNode.Hostname, mgmtPort, name, d.opts.Username, d.opts.Password)
+		if err != nil {
+			httpResult = classifyBootstrapError(err)
+			d.warnf(findingBucketOpenFailed,
+				"Failed to fetch the config of bucket `%s` over HTTP from `%s:%d` (error: %s)",
+				name, mgmtNode.Hostname, mgmtPort, err.Error())
+		}
+
+		d.log.Detail("Bucket `%s`: KV %s, HTTP %s", name, kvResult, httpResult)
+	}
+}
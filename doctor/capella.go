@@ -0,0 +1,30 @@
+package doctor
+
+import (
+	"strings"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// capellaDomainSuffix is the domain all Couchbase Capella connection strings live under
+const capellaDomainSuffix = ".cloud.couchbase.com"
+
+// isCapellaHost returns whether host belongs to a Couchbase Capella database
+func isCapellaHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(helpers.StripIPv6Brackets(host)), ".")
+	return strings.HasSuffix(host, capellaDomainSuffix)
+}
+
+// reportCapellaConnectivity gives Capella specific advice when the advertised nodes
+// could not be reached even though their hostnames resolved.
+func (d *diagnoser) reportCapellaConnectivity() {
+	if d.reportedCapellaConnectivity {
+		return
+	}
+
+	d.reportedCapellaConnectivity = true
+	d.errorf(findingCapellaUnreachable,
+		"Your Capella database resolved in DNS, but its nodes could not be reached.  Verify that"+
+			" the public IP address of this machine is on the database's list of allowed IP addresses,"+
+			" and that the database is not paused or turned off.")
+}
@@ -0,0 +1,77 @@
+package doctor
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// checkCouchAPIBases requests the views base URL each node advertises, which is
+// what views clients use, rather than one built from the node's host and port.
+func (d *diagnoser) checkCouchAPIBases(clusterInfo *clusterConfig, nodes []clusterNode) {
+	svcKey := "capi"
+	if d.tlsConfig != nil {
+		svcKey = "capiSSL"
+	}
+
+	for _, infoNode := range clusterInfo.Nodes {
+		base := infoNode.CouchAPIBase
+		if d.tlsConfig != nil {
+			base = infoNode.CouchAPIBaseHTTPS
+		}
+		if base == "" {
+			continue
+		}
+
+		baseURL, err := url.Parse(base)
+		if err != nil {
+			d.warnf(findingCouchAPIBase, "Node `%s` advertises an invalid views base URL `%s` (error: %s)",
+				infoNode.Hostname, base, err.Error())
+			continue
+		}
+
+		// The base URL normally matches the node's own hostname and views port
+		infoHost, _, err := net.SplitHostPort(infoNode.Hostname)
+		if err != nil {
+			infoHost = infoNode.Hostname
+		}
+		for _, node := range nodes {
+			if helpers.StripIPv6Brackets(node.Hostname) != helpers.StripIPv6Brackets(infoHost) {
+				continue
+			}
+
+			expected := helpers.JoinHostPort(node.Hostname, node.Services[svcKey])
+			if node.Services[svcKey] != 0 && baseURL.Host != expected {
+				d.warnf(findingCouchAPIBase,
+					"Node `%s` advertises the views base URL `%s`, which does not match its views"+
+						" service address `%s`.  Views clients connect to the advertised URL.",
+					infoNode.Hostname, base, expected)
+			}
+		}
+
+		req, _ := http.NewRequest("GET", base, nil)
+		req.SetBasicAuth(d.opts.Username, d.opts.Password)
+
+		resp, _, err := d.doHTTP(req)
+		if err != nil {
+			d.errorf(findingCouchAPIBase,
+				"Failed to connect to the views base URL `%s` advertised by node `%s` (error: %s).  Views"+
+					" clients use this URL, so view queries will fail.",
+				base, infoNode.Hostname, err.Error())
+			continue
+		}
+		closeResponse(resp)
+
+		if resp.StatusCode >= 500 {
+			d.warnf(findingCouchAPIBase,
+				"The views base URL `%s` advertised by node `%s` responded with an error (status code: %d)",
+				base, infoNode.Hostname, resp.StatusCode)
+			continue
+		}
+
+		d.log.Log("Views base URL `%s` advertised by node `%s` responded (status code: %d)",
+			base, infoNode.Hostname, resp.StatusCode)
+	}
+}
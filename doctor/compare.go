@@ -0,0 +1,140 @@
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+	"github.com/fatih/color"
+)
+
+// ReadReport decodes a report previously written in the json format
+func ReadReport(r io.Reader) (Report, error) {
+	var report Report
+	err := json.NewDecoder(r).Decode(&report)
+	return report, err
+}
+
+// describeRun names a report for the comparison header
+func describeRun(report Report) string {
+	desc := report.Started.Format(time.RFC3339)
+	if report.Label != "" {
+		desc += " (" + report.Label + ")"
+	}
+	return desc
+}
+
+// PrintComparison prints how report differs from an earlier report: the
+// warnings and errors which appeared or were resolved, how the KV latency of
+// each node changed, and which nodes and endpoints changed.
+func (report Report) PrintComparison(w io.Writer, earlier Report) {
+	fmt.Fprintf(w, "Comparing %s to %s:\n", describeRun(earlier), describeRun(report))
+	if report.ConnStr != earlier.ConnStr {
+		fmt.Fprintf(w, "Note: the runs diagnosed different connection strings (`%s` and `%s`)\n",
+			earlier.ConnStr, report.ConnStr)
+	}
+	if earlier.Interrupted || report.Interrupted {
+		fmt.Fprintf(w, "Note: at least one of the runs was interrupted, its results are incomplete\n")
+	}
+
+	delta := report.Delta(earlier)
+	changed := !delta.Empty()
+
+	fmt.Fprintf(w, "\nProblems:\n")
+	for _, key := range delta.Appeared {
+		fmt.Fprintf(w, "%s %s\n", color.RedString("+"), key)
+	}
+	for _, key := range delta.Resolved {
+		fmt.Fprintf(w, "%s %s\n", color.GreenString("-"), key)
+	}
+	if len(delta.Appeared) == 0 && len(delta.Resolved) == 0 {
+		fmt.Fprintf(w, "  No changes\n")
+	}
+
+	fmt.Fprintf(w, "\nKV latency (p95):\n")
+	earlierLatency := make(map[string]KVLatency)
+	for _, latency := range earlier.Latencies {
+		earlierLatency[helpers.JoinHostPort(latency.Host, latency.Port)] = latency
+	}
+	currentLatency := make(map[string]bool)
+	for _, latency := range report.Latencies {
+		address := helpers.JoinHostPort(latency.Host, latency.Port)
+		currentLatency[address] = true
+
+		before, ok := earlierLatency[address]
+		if !ok {
+			fmt.Fprintf(w, "%s `%s`: %s (not measured before)\n", color.YellowString("~"),
+				address, latency.P95.Round(time.Microsecond))
+			continue
+		}
+
+		diff := latency.P95 - before.P95
+		sign := "+"
+		if diff < 0 {
+			sign = "-"
+			diff = -diff
+		}
+		fmt.Fprintf(w, "%s `%s`: %s -> %s (%s%s)\n", color.YellowString("~"), address,
+			before.P95.Round(time.Microsecond), latency.P95.Round(time.Microsecond),
+			sign, diff.Round(time.Microsecond))
+	}
+	for _, latency := range earlier.Latencies {
+		address := helpers.JoinHostPort(latency.Host, latency.Port)
+		if !currentLatency[address] {
+			fmt.Fprintf(w, "%s `%s`: no longer measured (was %s)\n", color.YellowString("~"),
+				address, latency.P95.Round(time.Microsecond))
+		}
+	}
+	if len(report.Latencies) == 0 && len(earlier.Latencies) == 0 {
+		fmt.Fprintf(w, "  Not measured in either run\n")
+	}
+
+	fmt.Fprintf(w, "\nTopology:\n")
+	topologyChanged := false
+	for _, node := range hostsNotIn(report.Nodes, earlier.Nodes) {
+		fmt.Fprintf(w, "%s node `%s`\n", color.RedString("+"), node)
+		topologyChanged = true
+	}
+	for _, node := range hostsNotIn(earlier.Nodes, report.Nodes) {
+		fmt.Fprintf(w, "%s node `%s`\n", color.GreenString("-"), node)
+		topologyChanged = true
+	}
+
+	earlierEndpoints := make(map[string]Endpoint)
+	for _, endpoint := range earlier.Endpoints {
+		earlierEndpoints[endpoint.Service+" "+helpers.JoinHostPort(endpoint.Host, endpoint.Port)] = endpoint
+	}
+	for _, endpoint := range report.Endpoints {
+		before, ok := earlierEndpoints[endpoint.Service+" "+helpers.JoinHostPort(endpoint.Host, endpoint.Port)]
+		if !ok {
+			fmt.Fprintf(w, "%s endpoint %s\n", color.RedString("+"), endpoint)
+			topologyChanged = true
+		} else if before.Tested && endpoint.Tested && before.Reachable != endpoint.Reachable {
+			fmt.Fprintf(w, "%s endpoint %s (was reachable=%t)\n", color.YellowString("~"), endpoint, before.Reachable)
+			topologyChanged = true
+		}
+	}
+	if !topologyChanged {
+		fmt.Fprintf(w, "  No changes\n")
+	}
+
+	fmt.Fprintf(w, "\n")
+	if changed || topologyChanged {
+		fmt.Fprintf(w, "The runs differ, see listing above.\n")
+	} else {
+		fmt.Fprintf(w, "No differences in problems, latency regressions or topology.\n")
+	}
+}
+
+// hostsNotIn returns the hosts which do not appear in other
+func hostsNotIn(hosts, other []string) []string {
+	var out []string
+	for _, host := range hosts {
+		if !anyHostIn([]string{host}, other) {
+			out = append(out, host)
+		}
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package doctor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+func slowKVReport(host string, meanMs int) Report {
+	return Report{
+		ConnStr: "couchbase://" + host,
+		Entries: []helpers.LogEntry{
+			{Level: helpers.LogInfo, Message: "Diagnostics completed"},
+			{
+				Level:   helpers.LogWarn,
+				Finding: string(findingSlowKV),
+				Code:    findingDefinitions[findingSlowKV].Code,
+				Message: "Memcached service on `" + host + ":11210` on average took longer than 10ms" +
+					" (was: " + (time.Duration(meanMs) * time.Millisecond).String() + ") to reply.",
+			},
+		},
+		Latencies: []KVLatency{
+			{Host: host, Port: 11210, Mean: time.Duration(meanMs) * time.Millisecond, P95: 20 * time.Millisecond},
+		},
+	}
+}
+
+func TestDeltaIgnoresChangedValues(t *testing.T) {
+	earlier := slowKVReport("node1", 12)
+	report := slowKVReport("node1", 14)
+
+	if delta := report.Delta(earlier); !delta.Empty() {
+		t.Errorf("expected no delta, got %+v", delta)
+	}
+
+	var out bytes.Buffer
+	report.PrintComparison(&out, earlier)
+	if !strings.Contains(out.String(), "No differences") {
+		t.Errorf("expected no differences, got:\n%s", out.String())
+	}
+}
+
+func TestDeltaReportsOtherHosts(t *testing.T) {
+	earlier := slowKVReport("node1", 12)
+	report := slowKVReport("node2", 12)
+
+	delta := report.Delta(earlier)
+	if len(delta.Appeared) != 1 || !strings.Contains(delta.Appeared[0], "node2:11210") {
+		t.Errorf("expected the warning about `node2` to appear, got %v", delta.Appeared)
+	}
+	if len(delta.Resolved) != 1 || !strings.Contains(delta.Resolved[0], "node1:11210") {
+		t.Errorf("expected the warning about `node1` to be resolved, got %v", delta.Resolved)
+	}
+}
+
+func TestDeltaReportsOtherFindings(t *testing.T) {
+	earlier := slowKVReport("node1", 12)
+	report := slowKVReport("node1", 12)
+	report.Entries[1].Code = findingDefinitions[findingIdleTimeout].Code
+
+	delta := report.Delta(earlier)
+	if len(delta.Appeared) != 1 || len(delta.Resolved) != 1 {
+		t.Errorf("expected one appeared and one resolved warning, got %+v", delta)
+	}
+}
@@ -0,0 +1,668 @@
+package doctor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+type clusterConfigNode struct {
+	OptNode           string         `json:"optNode"`
+	ThisNode          bool           `json:"thisNode"`
+	CouchAPIBase      string         `json:"couchApiBase"`
+	CouchAPIBaseHTTPS string         `json:"couchApiBaseHTTPS"`
+	Status            string         `json:"status"`
+	Hostname          string         `json:"hostname"`
+	Version           string         `json:"version"`
+	Os                string         `json:"os"`
+	Ports             map[string]int `json:"Ports"`
+	Services          []string       `json:"services"`
+
+	ClusterCompatibility int `json:"clusterCompatibility"`
+}
+
+type clusterConfig struct {
+	Nodes   []clusterConfigNode `json:"nodes"`
+	Buckets struct {
+		URI string `json:"uri"`
+	} `json:"buckets"`
+}
+
+// CompatVersion returns the effective feature level of the cluster, which is
+// limited by its lowest node, encoded as major*0x10000+minor.  It returns 0 if
+// the cluster does not advertise it.
+func (config *clusterConfig) CompatVersion() int {
+	compat := 0
+	for _, node := range config.Nodes {
+		if node.ClusterCompatibility != 0 && (compat == 0 || node.ClusterCompatibility < compat) {
+			compat = node.ClusterCompatibility
+		}
+	}
+	return compat
+}
+
+// makeCompatVersion encodes a version the same way clusterCompatibility does
+func makeCompatVersion(major, minor int) int {
+	return major*0x10000 + minor
+}
+
+func formatCompatVersion(compat int) string {
+	return fmt.Sprintf("%d.%d", compat/0x10000, compat%0x10000)
+}
+
+type bucketConfig struct {
+	RawConfig []byte `json:"-"`
+
+	Name               string   `json:"name"`
+	BucketType         string   `json:"bucketType"`
+	ReplicaNumber      int      `json:"replicaNumber"`
+	EvictionPolicy     string   `json:"evictionPolicy"`
+	DurabilityMinLevel string   `json:"durabilityMinLevel"`
+	BucketCapabilities []string `json:"bucketCapabilities"`
+}
+
+// TypeName returns the bucket type as named by the UI and SDKs
+func (config *bucketConfig) TypeName() string {
+	if config.BucketType == "membase" {
+		return "couchbase"
+	}
+	return config.BucketType
+}
+
+// HasCapability returns whether the bucket advertises the named capability
+func (config *bucketConfig) HasCapability(name string) bool {
+	for _, capability := range config.BucketCapabilities {
+		if capability == name {
+			return true
+		}
+	}
+	return false
+}
+
+type collectionManifestCollection struct {
+	UID  string `json:"uid"`
+	Name string `json:"name"`
+}
+
+type collectionManifestScope struct {
+	UID         string                         `json:"uid"`
+	Name        string                         `json:"name"`
+	Collections []collectionManifestCollection `json:"collections"`
+}
+
+type collectionManifest struct {
+	UID    string                    `json:"uid"`
+	Scopes []collectionManifestScope `json:"scopes"`
+}
+
+func (manifest *collectionManifest) GetScope(name string) *collectionManifestScope {
+	for _, scope := range manifest.Scopes {
+		if scope.Name == name {
+			return &scope
+		}
+	}
+
+	return nil
+}
+
+func (scope *collectionManifestScope) GetCollection(name string) *collectionManifestCollection {
+	for _, collection := range scope.Collections {
+		if collection.Name == name {
+			return &collection
+		}
+	}
+
+	return nil
+}
+
+type bucketConfigAlternateNames struct {
+	Hostname string         `json:"hostname"`
+	Ports    map[string]int `json:"ports"`
+}
+
+type bucketConfigNodeExt struct {
+	ThisNode       bool                                  `json:"thisNode"`
+	Hostname       string                                `json:"hostname"`
+	Services       map[string]int                        `json:"services"`
+	AlternateNames map[string]bucketConfigAlternateNames `json:"alternateAddresses"`
+}
+
+type terseBucketConfig struct {
+	SourceHost string
+	RawConfig  []byte                `json:"-"`
+	UUID       string                `json:"uuid"`
+	Rev        uint                  `json:"rev"`
+	NodesExt   []bucketConfigNodeExt `json:"nodesExt"`
+}
+
+func (config *terseBucketConfig) GetSourceNodeExt() *bucketConfigNodeExt {
+	for _, node := range config.NodesExt {
+		if node.ThisNode {
+			return &node
+		}
+	}
+
+	return nil
+}
+
+// thisNodeCount returns how many nodes the config marks as the node it came
+// from, which is exactly one for a config served by the node itself
+func (config *terseBucketConfig) thisNodeCount() int {
+	count := 0
+	for _, node := range config.NodesExt {
+		if node.ThisNode {
+			count++
+		}
+	}
+	return count
+}
+
+// clusterNodeDifference compares the nodes of a terse bucket config with the
+// nodes of the cluster config, by their hostnames on the default network, and
+// returns the hosts only the cluster config lists and those only the bucket
+// config lists
+func clusterNodeDifference(bucket terseBucketConfig, cluster clusterConfig) (clusterOnly, bucketOnly []string) {
+	var bucketHosts []string
+	for _, node := range bucket.NodesExt {
+		host := node.Hostname
+		if host == "" {
+			host = bucket.SourceHost
+		}
+		bucketHosts = append(bucketHosts, helpers.StripIPv6Brackets(host))
+	}
+
+	var clusterHosts []string
+	for _, node := range cluster.Nodes {
+		host, _, err := net.SplitHostPort(node.Hostname)
+		if err != nil {
+			host = node.Hostname
+		}
+		clusterHosts = append(clusterHosts, helpers.StripIPv6Brackets(host))
+	}
+
+	for _, host := range clusterHosts {
+		if !anyHostIn([]string{host}, bucketHosts) {
+			clusterOnly = append(clusterOnly, host)
+		}
+	}
+	for _, host := range bucketHosts {
+		if !anyHostIn([]string{host}, clusterHosts) {
+			bucketOnly = append(bucketOnly, host)
+		}
+	}
+	return clusterOnly, bucketOnly
+}
+
+// errConfigUnexpected is returned for configs which parsed, but lack the fields
+// every server version provides, such as after the JSON layout changed
+var errConfigUnexpected = errors.New("config parsed but appears empty or unexpected")
+
+// validate checks that the config describes at least one node and its services
+func (config *terseBucketConfig) validate() error {
+	if len(config.NodesExt) == 0 {
+		return fmt.Errorf("%w (no nodes in `nodesExt`)", errConfigUnexpected)
+	}
+
+	for _, node := range config.NodesExt {
+		if len(node.Services) > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w (no services for any of the %d nodes in `nodesExt`)", errConfigUnexpected, len(config.NodesExt))
+}
+
+type clusterNode struct {
+	Hostname string
+	Services map[string]int
+}
+
+func clusterNodesFromTerseBucketConfig(config terseBucketConfig, networkType string) []clusterNode {
+	var out []clusterNode
+
+	for _, node := range config.NodesExt {
+		var newNode clusterNode
+
+		if node.Hostname == "" {
+			newNode.Hostname = config.SourceHost
+		} else {
+			newNode.Hostname = node.Hostname
+		}
+
+		newNode.Services = node.Services
+
+		if networkType != "default" {
+			netInfo, found := node.AlternateNames[networkType]
+			if !found {
+				return nil
+			}
+
+			if netInfo.Hostname != "" {
+				newNode.Hostname = netInfo.Hostname
+			}
+			if netInfo.Ports != nil {
+				newNode.Services = netInfo.Ports
+			}
+		}
+
+		out = append(out, newNode)
+	}
+
+	return out
+}
+
+// defaultServicePorts lists the well-known port of each service
+var defaultServicePorts = map[string]int{
+	"mgmt":           8091,
+	"mgmtSSL":        18091,
+	"capi":           8092,
+	"capiSSL":        18092,
+	"n1ql":           8093,
+	"n1qlSSL":        18093,
+	"fts":            8094,
+	"ftsSSL":         18094,
+	"cbas":           8095,
+	"cbasSSL":        18095,
+	"indexHttp":      9102,
+	"indexHttps":     19102,
+	"backupAPI":      8097,
+	"backupAPIHTTPS": 18097,
+	"kv":             11210,
+	"kvSSL":          11207,
+}
+
+// nonDefaultServicePorts lists every service a node advertises on a port other
+// than the service's well-known port, formatted as `service=port`.
+func nonDefaultServicePorts(node clusterNode) []string {
+	var out []string
+	for service, port := range node.Services {
+		defaultPort, known := defaultServicePorts[service]
+		if known && port != 0 && port != defaultPort {
+			out = append(out, fmt.Sprintf("%s=%d (default %d)", service, port, defaultPort))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// hasServices returns whether node advertises any service at all
+func (node clusterNode) hasServices() bool {
+	for _, port := range node.Services {
+		if port != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceDistribution counts how many nodes advertise each service
+func serviceDistribution(nodes []clusterNode) map[string]int {
+	out := make(map[string]int)
+	for _, node := range nodes {
+		for service, port := range node.Services {
+			if port != 0 {
+				out[service]++
+			}
+		}
+	}
+	return out
+}
+
+// serviceConflicts describes every address which is advertised more than once
+// across nodes, and every port which a node advertises for several services.
+// Either means the config is corrupt, or alternate addresses are misapplied.
+func serviceConflicts(nodes []clusterNode) []string {
+	var out []string
+
+	addrNodes := make(map[string][]int)
+	for i, node := range nodes {
+		portServices := make(map[int][]string)
+		for service, port := range node.Services {
+			if port != 0 {
+				portServices[port] = append(portServices[port], service)
+			}
+		}
+
+		for port, services := range portServices {
+			addr := helpers.JoinHostPort(node.Hostname, port)
+			addrNodes[addr] = append(addrNodes[addr], i)
+
+			if len(services) > 1 {
+				sort.Strings(services)
+				out = append(out, fmt.Sprintf("node `%s` advertises `%s` on the same port %d",
+					node.Hostname, strings.Join(services, "`, `"), port))
+			}
+		}
+	}
+
+	for addr, nodeIdxs := range addrNodes {
+		if len(nodeIdxs) > 1 {
+			out = append(out, fmt.Sprintf("%d nodes advertise the same address `%s`", len(nodeIdxs), addr))
+		}
+	}
+
+	sort.Strings(out)
+	return out
+}
+
+func formatServiceDistribution(counts map[string]int) string {
+	services := make([]string, 0, len(counts))
+	for service := range counts {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	parts := make([]string, 0, len(services))
+	for _, service := range services {
+		parts = append(parts, fmt.Sprintf("%s: %d", service, counts[service]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func networkFromTerseBucketConfig(config terseBucketConfig) string {
+	// Check if we connected using any of the ports associated with the default
+	// configurations that are available.
+	for _, node := range config.NodesExt {
+		for _, svcPort := range node.Services {
+			if fmt.Sprintf("%s:%d", node.Hostname, svcPort) == config.SourceHost {
+				return "default"
+			}
+		}
+	}
+
+	for _, node := range config.NodesExt {
+		if _, found := node.AlternateNames["external"]; found {
+			return "external"
+		}
+	}
+
+	return "default"
+}
+
+// replaceHostPlaceholder substitutes the `$HOST` placeholder the server uses for
+// its own address.  Hostnames in the config are kept unbracketed, IPv6 literals
+// are bracketed where addresses and URIs are built from them.
+func replaceHostPlaceholder(configBytes []byte, host string) []byte {
+	return bytes.Replace(configBytes, []byte("$HOST"), []byte(helpers.StripIPv6Brackets(host)), -1)
+}
+
+var errIncorrectCredentials = errors.New("incorrect bucket/password")
+
+// errConfigTruncated is returned when the connection dropped partway through a
+// config, which points at network or proxy instability rather than the cluster
+var errConfigTruncated = errors.New("connection dropped while reading config")
+
+// notCouchbaseError is returned when an endpoint responded successfully, but
+// with something other than a Couchbase config, such as the HTML page of a
+// captive portal, a web application firewall or a login redirect
+type notCouchbaseError struct {
+	ContentType string
+	Server      string
+}
+
+func (err *notCouchbaseError) Error() string {
+	server := err.Server
+	if server == "" {
+		server = "none"
+	}
+	return fmt.Sprintf("the endpoint responded but does not appear to be a Couchbase cluster"+
+		" (content type: %s, server: %s)", err.ContentType, server)
+}
+
+// checkCouchbaseResponse returns a notCouchbaseError if a successful response
+// does not carry JSON, which every config endpoint of the cluster responds with
+func checkCouchbaseResponse(resp *http.Response, body []byte) error {
+	contentType := resp.Header.Get("Content-Type")
+	trimmed := bytes.TrimSpace(body)
+
+	isJSON := contentType == "" || strings.Contains(strings.ToLower(contentType), "json")
+	if isJSON && (len(trimmed) == 0 || trimmed[0] != '<') {
+		return nil
+	}
+
+	if contentType == "" {
+		contentType = "none"
+	}
+	return &notCouchbaseError{
+		ContentType: contentType,
+		Server:      resp.Header.Get("Server"),
+	}
+}
+
+// readConfigBody reads a config from body, telling a response which was cut
+// short apart from other read errors
+func readConfigBody(body io.Reader) ([]byte, error) {
+	configBytes, err := ioutil.ReadAll(body)
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		return nil, fmt.Errorf("%w (read %d bytes, error: %s)", errConfigTruncated, len(configBytes), err)
+	} else if err != nil {
+		return nil, err
+	}
+	return configBytes, nil
+}
+
+// unmarshalConfig decodes a config, reporting JSON which ends early as a
+// truncated config rather than as malformed
+func unmarshalConfig(configBytes []byte, v interface{}) error {
+	err := json.Unmarshal(configBytes, v)
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) && syntaxErr.Offset >= int64(len(configBytes)) {
+		return fmt.Errorf("%w (read %d bytes, error: %s)", errConfigTruncated, len(configBytes), err)
+	}
+	return err
+}
+
+func (d *diagnoser) fetchHTTPTerseBucketConfig(host string, port int, bucket, user, pass string) (terseBucketConfig, error) {
+	if user == "" {
+		user = bucket
+	}
+
+	scheme := "http"
+	if d.tlsConfig != nil {
+		scheme = "https"
+	}
+
+	uri := fmt.Sprintf("%s://%s/pools/default/b/%s", scheme, helpers.JoinHostPort(host, port), url.PathEscape(bucket))
+	req, _ := http.NewRequest("GET", uri, nil)
+	req.SetBasicAuth(user, pass)
+
+	resp, _, err := d.doHTTP(req)
+	if err != nil {
+		return terseBucketConfig{}, err
+	}
+	defer closeResponse(resp)
+
+	err = checkRedirect(resp)
+	if err != nil {
+		return terseBucketConfig{}, err
+	}
+
+	if resp.StatusCode != 200 {
+		if resp.StatusCode == 401 {
+			return terseBucketConfig{}, errIncorrectCredentials
+		}
+
+		return terseBucketConfig{}, fmt.Errorf("http error (status code: %d)", resp.StatusCode)
+	}
+
+	configBytes, err := readConfigBody(resp.Body)
+	if err != nil {
+		return terseBucketConfig{}, err
+	}
+
+	err = checkCouchbaseResponse(resp, configBytes)
+	if err != nil {
+		return terseBucketConfig{}, err
+	}
+
+	configBytes = replaceHostPlaceholder(configBytes, host)
+
+	var config terseBucketConfig
+	err = unmarshalConfig(configBytes, &config)
+	if err != nil {
+		return terseBucketConfig{}, err
+	}
+
+	err = config.validate()
+	if err != nil {
+		return terseBucketConfig{}, err
+	}
+	d.redactTerseHosts(config)
+
+	config.SourceHost = helpers.StripIPv6Brackets(host)
+	config.RawConfig = configBytes
+
+	return config, nil
+}
+
+func (d *diagnoser) fetchCccpTerseBucketConfig(host string, port int, bucket string) (terseBucketConfig, error) {
+	client, err := d.dialMemd(host, port, bucket)
+	if err != nil {
+		return terseBucketConfig{}, err
+	}
+	defer client.Close()
+
+	configBytes, err := client.GetConfig()
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return terseBucketConfig{}, fmt.Errorf("%w (error: %s)", errConfigTruncated, err)
+	} else if err != nil {
+		return terseBucketConfig{}, err
+	}
+
+	configBytes = replaceHostPlaceholder(configBytes, host)
+
+	var config terseBucketConfig
+	err = unmarshalConfig(configBytes, &config)
+	if err != nil {
+		return terseBucketConfig{}, err
+	}
+
+	err = config.validate()
+	if err != nil {
+		return terseBucketConfig{}, err
+	}
+	d.redactTerseHosts(config)
+
+	config.SourceHost = helpers.StripIPv6Brackets(host)
+	config.RawConfig = configBytes
+
+	return config, nil
+}
+
+func (d *diagnoser) fetchBucketConfig(scheme, host string, port int, bucket, user, pass string) (bucketConfig, error) {
+	uri := fmt.Sprintf("%s://%s/pools/default/buckets/%s", scheme, helpers.JoinHostPort(host, port), url.PathEscape(bucket))
+	req, _ := http.NewRequest("GET", uri, nil)
+	req.SetBasicAuth(user, pass)
+
+	resp, _, err := d.doHTTP(req)
+	if err != nil {
+		return bucketConfig{}, err
+	}
+	defer closeResponse(resp)
+
+	err = checkRedirect(resp)
+	if err != nil {
+		return bucketConfig{}, err
+	}
+
+	if resp.StatusCode != 200 {
+		return bucketConfig{}, fmt.Errorf("http error (status code: %d)", resp.StatusCode)
+	}
+
+	configBytes, err := readConfigBody(resp.Body)
+	if err != nil {
+		return bucketConfig{}, err
+	}
+
+	var config bucketConfig
+	err = unmarshalConfig(configBytes, &config)
+	if err != nil {
+		return bucketConfig{}, err
+	}
+
+	config.RawConfig = configBytes
+
+	return config, nil
+}
+
+// printRawConfig writes a fetched config, pretty-printed, to the config output
+func (d *diagnoser) printRawConfig(name string, configBytes []byte) {
+	if d.opts.ConfigOutput == nil {
+		return
+	}
+
+	var out bytes.Buffer
+	err := json.Indent(&out, configBytes, "", "  ")
+	if err != nil {
+		d.warnf(findingRawConfigMalformed, "Failed to pretty-print the raw %s config (error: %s)",
+			name, err.Error())
+		out.Reset()
+		out.Write(configBytes)
+	}
+	out.WriteString("\n")
+
+	_, err = d.opts.ConfigOutput.Write(out.Bytes())
+	if err != nil {
+		d.warnf(findingOutputFailed, "Failed to write the raw %s config (error: %s)", name, err.Error())
+		return
+	}
+
+	d.log.Log("Wrote the raw %s config", name)
+}
+
+// errManifestNotFound is returned when the management service has no collection
+// manifest for a bucket, either because the cluster does not support collections
+// or because the bucket does not exist
+var errManifestNotFound = errors.New("the collection manifest was not found")
+
+func (d *diagnoser) fetchCollectionManifest(scheme, host string, port int, bucket, user, pass string) (collectionManifest, error) {
+	uri := fmt.Sprintf("%s://%s/pools/default/buckets/%s/scopes", scheme, helpers.JoinHostPort(host, port), url.PathEscape(bucket))
+	req, _ := http.NewRequest("GET", uri, nil)
+	req.SetBasicAuth(user, pass)
+
+	resp, _, err := d.doHTTP(req)
+	if err != nil {
+		return collectionManifest{}, err
+	}
+	defer closeResponse(resp)
+
+	err = checkRedirect(resp)
+	if err != nil {
+		return collectionManifest{}, err
+	}
+
+	if resp.StatusCode != 200 {
+		if resp.StatusCode == 404 {
+			return collectionManifest{}, errManifestNotFound
+		}
+		if resp.StatusCode == 401 || resp.StatusCode == 403 {
+			return collectionManifest{}, errors.New("insufficient permissions to read the collection manifest")
+		}
+
+		return collectionManifest{}, fmt.Errorf("http error (status code: %d)", resp.StatusCode)
+	}
+
+	manifestBytes, err := readConfigBody(resp.Body)
+	if err != nil {
+		return collectionManifest{}, err
+	}
+
+	var manifest collectionManifest
+	err = unmarshalConfig(manifestBytes, &manifest)
+	if err != nil {
+		return collectionManifest{}, err
+	}
+
+	return manifest, nil
+}
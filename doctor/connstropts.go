@@ -0,0 +1,314 @@
+package doctor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/couchbaselabs/gocbconnstr"
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// knownConnStrOptions lists the connection string options commonly understood by the SDKs
+var knownConnStrOptions = map[string]bool{
+	"network":                      true,
+	"ssl":                          true,
+	"certpath":                     true,
+	"truststorepath":               true,
+	"bootstrap_on":                 true,
+	"connect_timeout":              true,
+	"kv_timeout":                   true,
+	"kv_durable_timeout":           true,
+	"view_timeout":                 true,
+	"query_timeout":                true,
+	"analytics_timeout":            true,
+	"search_timeout":               true,
+	"management_timeout":           true,
+	"kv_pool_size":                 true,
+	"max_http_connections":         true,
+	"idle_http_connection_timeout": true,
+	"config_poll_interval":         true,
+	"config_poll_floor_interval":   true,
+	"enable_tcp_keepalives":        true,
+	"tcp_keepalive_time":           true,
+	"enable_mutation_tokens":       true,
+	"enable_server_durations":      true,
+	"enable_tracing":               true,
+	"compression":                  true,
+	"compression_min_size":         true,
+	"compression_min_ratio":        true,
+	"orphaned_response_logging":    true,
+	"sasl_mech_force":              true,
+}
+
+// deprecatedConnStrOptions maps connection string options of older SDK
+// versions to the options which replaced them
+var deprecatedConnStrOptions = map[string]string{
+	"operation_timeout":     "kv_timeout",
+	"durability_timeout":    "kv_durable_timeout",
+	"config_total_timeout":  "connect_timeout",
+	"n1ql_timeout":          "query_timeout",
+	"views_timeout":         "view_timeout",
+	"fts_timeout":           "search_timeout",
+	"http_poolsize":         "max_http_connections",
+	"http_idle_timeout":     "idle_http_connection_timeout",
+	"fetch_mutation_tokens": "enable_mutation_tokens",
+	"http_redirects":        "",
+	"bootstrap_http":        "bootstrap_on",
+}
+
+// checkConnStrOptions logs the options carried by the connection string, and
+// warns about any which are not understood, as these are usually typos.
+func (d *diagnoser) checkConnStrOptions(connSpec gocbconnstr.ConnSpec) {
+	names := make([]string, 0, len(connSpec.Options))
+	for name := range connSpec.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var unknownNames []string
+	for _, name := range names {
+		d.log.Log("Connection string specifies option `%s=%s`",
+			name, strings.Join(connSpec.Options[name], ","))
+
+		if modern, ok := deprecatedConnStrOptions[name]; ok {
+			if modern == "" {
+				d.warnf(findingDeprecatedOption,
+					"Connection string specifies option `%s`, which is deprecated and no longer"+
+						" has an equivalent.  Remove it from your connection string.", name)
+			} else {
+				d.warnf(findingDeprecatedOption,
+					"Connection string specifies option `%s`, which is deprecated.  Use `%s`"+
+						" instead, current SDKs may ignore `%s`.", name, modern, name)
+			}
+		} else if !knownConnStrOptions[name] {
+			unknownNames = append(unknownNames, name)
+		}
+	}
+
+	// Management URLs were once used as connection strings, which leaves the
+	//  REST path where the bucket name is expected.
+	if connSpec.Bucket == "pools" || strings.HasPrefix(connSpec.Bucket, "pools/") {
+		d.warnf(findingDeprecatedOption,
+			"Connection string names bucket `%s`, which looks like a legacy management URL"+
+				" path.  Specify the bucket name alone, as in `couchbase://host/bucket`.",
+			connSpec.Bucket)
+	}
+
+	if len(unknownNames) > 0 {
+		d.warnf(findingUnknownOption,
+			"Your connection string specifies options which are not recognized: `%s`.  Check"+
+				" these for typos, as SDKs generally ignore options they do not understand.",
+			strings.Join(unknownNames, "`, `"))
+	}
+
+	network := connSpec.GetOptionString("network")
+	switch network {
+	case "", "auto", "default", "external":
+	default:
+		d.warnf(findingUnusualNetwork,
+			"Connection string requests network `%s`.  The doctor will use the alternate addresses"+
+				" of this name, but the usual values are `auto`, `default` and `external`.",
+			network)
+	}
+
+	ssl := connSpec.GetOptionString("ssl")
+	if ssl != "" && ssl != "no_verify" {
+		d.warnf(findingIneffectiveSSLOption,
+			"Connection string specifies `ssl=%s`, the only supported value is `no_verify`.",
+			ssl)
+	}
+}
+
+// connStrNetwork returns the network type requested by the connection string,
+// or an empty string if the network should be detected automatically.
+func connStrNetwork(connSpec gocbconnstr.ConnSpec) string {
+	network := connSpec.GetOptionString("network")
+	if network == "auto" {
+		return ""
+	}
+	return network
+}
+
+// connStrSSLSignals describes the parts of a connection string which indicate
+// secured and plaintext connections respectively
+func connStrSSLSignals(connSpec gocbconnstr.ConnSpec) ([]string, []string) {
+	var secured, plaintext []string
+	switch connSpec.Scheme {
+	case "couchbases":
+		secured = append(secured, "the `couchbases://` scheme")
+	case "couchbase", "http":
+		plaintext = append(plaintext, fmt.Sprintf("the `%s://` scheme", connSpec.Scheme))
+	}
+
+	for _, address := range connSpec.Addresses {
+		service := serviceForDefaultPort(address.Port)
+		if address.Port <= 0 || service == "" {
+			continue
+		}
+
+		signal := fmt.Sprintf("%s port `%s:%d`", serviceName(service), address.Host, address.Port)
+		if plaintextOrTLS(service) == "TLS" {
+			secured = append(secured, signal)
+		} else {
+			plaintext = append(plaintext, signal)
+		}
+	}
+
+	return secured, plaintext
+}
+
+// overrideMgmtPort makes the HTTP bootstrap hosts which the connection string
+// lists without a port use the requested management port instead of the
+// default one.  Ports the cluster configuration advertises are unaffected.
+func (d *diagnoser) overrideMgmtPort(connSpec gocbconnstr.ConnSpec, spec *gocbconnstr.ResolvedConnSpec) {
+	explicitPort := make(map[string]bool)
+	for _, address := range connSpec.Addresses {
+		if address.Port > 0 {
+			explicitPort[address.Host] = true
+		}
+	}
+
+	overridden := 0
+	for i, address := range spec.HttpHosts {
+		if !explicitPort[address.Host] {
+			spec.HttpHosts[i].Port = d.opts.MgmtPort
+			overridden++
+		}
+	}
+
+	if overridden == 0 {
+		d.warnf(findingIneffectiveMgmtPort,
+			"The management port override (%d) has no effect, as every HTTP bootstrap host"+
+				" specifies its port or the hosts were taken from a DNS SRV record",
+			d.opts.MgmtPort)
+		return
+	}
+
+	d.log.Log("Using management port %d for the %d HTTP bootstrap hosts without an explicit port",
+		d.opts.MgmtPort, overridden)
+}
+
+// connStrPortIssues describes the ports of a resolved connection string which
+// cannot be right for the list they ended up in, per host.  These are usually
+// caused by hand-editing ports into the connection string.
+func connStrPortIssues(spec gocbconnstr.ResolvedConnSpec) []string {
+	kvKey, otherKvKey, mgmtKey := "kv", "kvSSL", "mgmt"
+	if spec.UseSsl {
+		kvKey, otherKvKey, mgmtKey = "kvSSL", "kv", "mgmtSSL"
+	}
+
+	var out []string
+	checkList := func(hosts []gocbconnstr.Address, method, expectedKey string) {
+		hostPorts := make(map[string][]int)
+		for _, address := range hosts {
+			hostPorts[address.Host] = append(hostPorts[address.Host], address.Port)
+
+			service := serviceForDefaultPort(address.Port)
+			switch {
+			case service == "" || service == expectedKey || !isBootstrapService(service):
+			case method == "CCCP" && service == otherKvKey:
+				// Reported as conflicting SSL signals instead
+			default:
+				out = append(out, fmt.Sprintf("`%s:%d` is listed for %s, but %d is the default port of the"+
+					" `%s` service", address.Host, address.Port, method, address.Port, service))
+			}
+		}
+
+		for _, address := range hosts {
+			ports := hostPorts[address.Host]
+			if len(ports) > 1 {
+				portStrs := make([]string, 0, len(ports))
+				for _, port := range ports {
+					portStrs = append(portStrs, strconv.Itoa(port))
+				}
+				out = append(out, fmt.Sprintf("`%s` is listed for %s with several ports (%s)",
+					address.Host, method, strings.Join(portStrs, ", ")))
+				hostPorts[address.Host] = nil
+			}
+		}
+	}
+	checkList(spec.MemdHosts, "CCCP", kvKey)
+	checkList(spec.HttpHosts, "HTTP", mgmtKey)
+
+	return out
+}
+
+// isBootstrapService returns whether SDKs can bootstrap against service
+func isBootstrapService(service string) bool {
+	switch service {
+	case "kv", "kvSSL", "mgmt", "mgmtSSL":
+		return true
+	}
+	return false
+}
+
+// serviceName returns the display name of a service's key
+func serviceName(service string) string {
+	for _, known := range topologyServices {
+		if service == known.PlainKey || service == known.SSLKey {
+			return known.Name
+		}
+	}
+	return service
+}
+
+// nonBootstrapSeedPorts describes the explicit ports of a connection string
+// which are the default port of a service SDKs cannot bootstrap against, such
+// as a query port copied from a query endpoint.
+func nonBootstrapSeedPorts(connSpec gocbconnstr.ConnSpec) []string {
+	var out []string
+	for _, address := range connSpec.Addresses {
+		if address.Port <= 0 {
+			continue
+		}
+
+		service := serviceForDefaultPort(address.Port)
+		if service != "" && !isBootstrapService(service) {
+			out = append(out, fmt.Sprintf("`%s:%d`, the default port of the %s service",
+				address.Host, address.Port, serviceName(service)))
+		}
+	}
+	return out
+}
+
+// serviceForDefaultPort returns the service whose well-known port is port, if any
+func serviceForDefaultPort(port int) string {
+	for service, defaultPort := range defaultServicePorts {
+		if defaultPort == port {
+			return service
+		}
+	}
+	return ""
+}
+
+func plaintextOrTLS(service string) string {
+	if strings.HasSuffix(service, "SSL") || strings.HasSuffix(service, "HTTPS") {
+		return "TLS"
+	}
+	return "plaintext"
+}
+
+// resolvedConnSpec converts a resolved connection string into the form the
+// bootstrap checks operate on
+func resolvedConnSpec(resolved *helpers.ResolvedConnStr) gocbconnstr.ResolvedConnSpec {
+	return gocbconnstr.ResolvedConnSpec{
+		UseSsl:    resolved.UseSSL,
+		MemdHosts: connStrAddresses(resolved.CCCPHosts),
+		HttpHosts: connStrAddresses(resolved.HTTPHosts),
+		Bucket:    resolved.Bucket,
+		Options:   resolved.Options,
+	}
+}
+
+func connStrAddresses(hosts []helpers.ConnStrHost) []gocbconnstr.Address {
+	out := make([]gocbconnstr.Address, 0, len(hosts))
+	for _, host := range hosts {
+		out = append(out, gocbconnstr.Address{
+			Host: host.Host,
+			Port: host.Port,
+		})
+	}
+	return out
+}
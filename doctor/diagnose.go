@@ -0,0 +1,1398 @@
+package doctor
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/couchbaselabs/gocbconnstr"
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+func (d *diagnoser) diagnose() error {
+	connStr := d.opts.ConnStr
+	username := d.opts.Username
+	password := d.opts.Password
+	scope := d.opts.Scope
+	collection := d.opts.Collection
+
+	//======================================================================
+	//  CONNECTION STRING
+	//======================================================================
+	if err := d.setPhase(phaseConnStr); err != nil {
+		return err
+	}
+	d.log.Log("Parsing connection string `%s`", connStr)
+
+	// The connection string library resolves SRV records using the system resolver,
+	//  the records of the requested DNS server take precedence.
+	var lookupSRV helpers.SRVLookupFunc
+	srvOverridden := false
+	if d.opts.DNSServer != "" {
+		lookupSRV = func(name string) ([]*net.SRV, error) {
+			addrs, err := d.lookupSRV(name)
+			srvOverridden = len(addrs) > 0
+			return addrs, err
+		}
+	}
+
+	resolved, err := helpers.ResolveConnStrWith(connStr, lookupSRV)
+	if err != nil {
+		d.errorf(findingConnStrInvalid, "Failed to resolve connection string `%s` (error: %s)",
+			connStr, err.Error())
+		return err
+	}
+	connSpec := resolved.Spec
+	resConnSpec := resolvedConnSpec(resolved)
+
+	connSpecSrv := resolved.SRVRecord
+	if connSpecSrv != "" {
+		d.log.Log("Connection string was parsed as a potential DNS SRV record")
+	}
+
+	if connSpec.Scheme == "http" {
+		d.warnf(findingHTTPScheme,
+			"Connection string is using the deprecated `http://` scheme.  Use"+
+				" the `couchbase://` scheme instead!")
+	}
+
+	if d.opts.DNSServer != "" && resolved.FromSRV && !srvOverridden {
+		d.warnf(findingSrvServerMismatch,
+			"The system resolver returned DNS SRV records for `%s`, but DNS server `%s` did not."+
+				"  Bootstrap will use the records returned by the system resolver.",
+			connSpecSrv, d.opts.DNSServer)
+	}
+
+	if resConnSpec.UseSsl {
+		d.log.Log("Connection string specifies to use secured connections")
+	}
+
+	if d.opts.MgmtPort != 0 {
+		d.overrideMgmtPort(connSpec, &resConnSpec)
+	}
+
+	isCapella := len(connSpec.Addresses) > 0 && isCapellaHost(connSpec.Addresses[0].Host)
+	if isCapella {
+		d.log.Detail("Connection string refers to a Couchbase Capella database")
+
+		if !resConnSpec.UseSsl {
+			d.errorf(findingCapellaNoTLS,
+				"Couchbase Capella only accepts secured connections, but your connection string"+
+					" does not use the `couchbases://` scheme.  Use the connection string shown"+
+					" in the Capella UI, which starts with `couchbases://`.")
+		}
+
+		if username == "" {
+			d.errorf(findingCapellaNoUsername,
+				"Couchbase Capella requires authenticating as a database user, but no username"+
+					" was specified (--username).  Create database credentials in the Capella UI.")
+		}
+	}
+
+	d.log.Log("Connection string identifies the following CCCP endpoints:")
+	for i, host := range resConnSpec.MemdHosts {
+		d.log.Log("  %d. %s:%d", i+1, host.Host, host.Port)
+	}
+
+	d.log.Log("Connection string identifies the following HTTP endpoints:")
+	for i, host := range resConnSpec.HttpHosts {
+		d.log.Log("  %d. %s:%d", i+1, host.Host, host.Port)
+	}
+
+	// SRV records only ever produce CCCP endpoints, so there is nothing to compare then
+	if len(resConnSpec.MemdHosts) > 0 && len(resConnSpec.HttpHosts) > 0 {
+		memdOnlyHosts := hostsMissingFrom(resConnSpec.MemdHosts, resConnSpec.HttpHosts)
+		httpOnlyHosts := hostsMissingFrom(resConnSpec.HttpHosts, resConnSpec.MemdHosts)
+
+		if len(memdOnlyHosts) > 0 || len(httpOnlyHosts) > 0 {
+			d.warnf(findingMismatchedEndpoints,
+				"Your connection string's CCCP and HTTP endpoints do not describe the same nodes"+
+					" (CCCP only: %s; HTTP only: %s).  This is usually caused by specifying explicit"+
+					" ports on some hosts, which restricts them to a single bootstrap method.  Leave"+
+					" out the ports when your cluster uses the default ones.",
+				formatHostList(memdOnlyHosts), formatHostList(httpOnlyHosts))
+		}
+	}
+
+	securedSignals, plaintextSignals := connStrSSLSignals(connSpec)
+	if len(securedSignals) > 0 && len(plaintextSignals) > 0 {
+		d.warnf(findingMixedSSL,
+			"Your connection string mixes signals for secured and plaintext connections (secured: %s;"+
+				" plaintext: %s).  How SDKs resolve this differs between versions, use"+
+				" `couchbases://` with the TLS ports, or `couchbase://` with the plaintext ports.",
+			strings.Join(securedSignals, ", "), strings.Join(plaintextSignals, ", "))
+	}
+
+	for _, seed := range nonBootstrapSeedPorts(connSpec) {
+		d.warnf(findingNonBootstrapPort,
+			"Your connection string specifies %s.  SDKs cannot bootstrap against this service, so"+
+				" this is unlikely to be a valid seed endpoint.  Only the Key Value (11210, or 11207"+
+				" for TLS) and management (8091, or 18091 for TLS) ports are.",
+			seed)
+	}
+
+	for _, issue := range connStrPortIssues(resConnSpec) {
+		d.warnf(findingInconsistentPorts,
+			"Your connection string's ports are inconsistent: %s.  Check the ports you specified by"+
+				" hand, or leave them out when your cluster uses the default ones.",
+			issue)
+	}
+
+	if resConnSpec.Bucket != "" {
+		d.log.Log("Connection string specifies bucket `%s`", resConnSpec.Bucket)
+	} else if d.opts.Bucket != "" {
+		resConnSpec.Bucket = d.opts.Bucket
+		d.log.Log("Connection string does not specify a bucket, using bucket `%s`", resConnSpec.Bucket)
+	} else {
+		d.warnf(findingNoBucket,
+			"Your connection string does not specify a bucket.  The doctor can check DNS, TLS and"+
+				" the reachability of the cluster's services, but can only bootstrap via CCCP against"+
+				" clusters running 7.0 or later, and cannot check the bucket's configuration or"+
+				" collections.  Add the bucket to the connection string (`couchbase://host/bucket`),"+
+				" or pass `--bucket` for full diagnostics.")
+	}
+	d.nodeHosts = seedHostNames(resConnSpec)
+
+	d.checkConnStrOptions(connSpec)
+	d.checkSDKCapabilities()
+
+	//======================================================================
+	//  SSL
+	//======================================================================
+	if err := d.setPhase(phaseSSL); err != nil {
+		return err
+	}
+	if resConnSpec.UseSsl {
+		if connSpec.GetOptionString("ssl") == "no_verify" {
+			d.log.Log("Connection string specifies `ssl=no_verify`, skipping server certificate verification")
+
+			tlsConfig := &tls.Config{}
+			if d.tlsConfig != nil {
+				tlsConfig = d.tlsConfig.Clone()
+			}
+			tlsConfig.InsecureSkipVerify = true
+			d.setTLSConfig(tlsConfig)
+		} else if d.tlsConfig == nil {
+			d.warnf(findingNoTLSCA, "No certificate authority file specified (--tls-ca), skipping"+
+				" server certificate verification for this run.")
+
+			d.setTLSConfig(&tls.Config{
+				InsecureSkipVerify: true,
+			})
+		}
+	} else {
+		if connSpec.GetOptionString("ssl") != "" {
+			d.warnf(findingIneffectiveSSLOption,
+				"Connection string specifies the `ssl` option, but it has no effect as the connection"+
+					" string does not use the `couchbases://` scheme.")
+		}
+
+		d.setTLSConfig(nil)
+	}
+
+	if d.opts.TLSServerName != "" {
+		if !resConnSpec.UseSsl {
+			d.warnf(findingSNIWithoutTLS,
+				"A TLS server name override was specified (--tls-sni), but it has no effect as"+
+					" the connection string does not use the `couchbases://` scheme.")
+		} else if len(resConnSpec.HttpHosts) > 0 {
+			d.log.Log("Using TLS server name `%s` for all secured connections", d.opts.TLSServerName)
+			d.checkSNIOverride(resConnSpec.HttpHosts[0].Host, resConnSpec.HttpHosts[0].Port)
+		} else if len(resConnSpec.MemdHosts) > 0 {
+			d.log.Log("Using TLS server name `%s` for all secured connections", d.opts.TLSServerName)
+			d.checkSNIOverride(resConnSpec.MemdHosts[0].Host, resConnSpec.MemdHosts[0].Port)
+		}
+	}
+
+	//======================================================================
+	//  CREDENTIALS
+	//======================================================================
+	if username != "" {
+		if err := d.setPhase(phaseCredentials); err != nil {
+			return err
+		}
+		if d.credentialsRejected(resConnSpec.HttpHosts) {
+			return nil
+		}
+	}
+
+	//======================================================================
+	//  DNS
+	//======================================================================
+	if err := d.setPhase(phaseDNS); err != nil {
+		return err
+	}
+	if d.opts.DNSServer != "" {
+		d.log.Log("Performing all DNS lookups against DNS server `%s`", d.opts.DNSServer)
+	}
+
+	if d.opts.SOCKS5 != "" {
+		d.log.Log("Connections are made through a SOCKS5 proxy, which resolves hostnames itself.  The" +
+			" following DNS lookups are performed locally, and may fail for hosts only resolvable behind the proxy.")
+	}
+
+	warnSingleHost := false
+	if len(connSpec.Addresses) == 1 {
+		warnSingleHost = true
+	}
+
+	dnsHosts := connSpec.Addresses
+	if connSpecSrv != "" {
+		srvAddrs, _ := d.lookupSRV(connSpecSrv)
+		aAddrs, _ := d.lookupHost(connSpec.Addresses[0].Host)
+		srvTargetAddrs := make(map[string]bool)
+
+		if len(srvAddrs) > 0 {
+			// Don't warn for single-hosts if using DNS SRV
+			warnSingleHost = false
+
+			d.log.Log("DNS SRV record `%s` has the following targets:", connSpecSrv)
+			for i, addr := range srvAddrs {
+				d.redactHost(strings.TrimSuffix(addr.Target, "."))
+				d.log.Log("  %d. %s:%d (priority: %d, weight: %d)",
+					i+1, strings.TrimSuffix(addr.Target, "."), addr.Port, addr.Priority, addr.Weight)
+			}
+
+			for _, issue := range srvRecordIssues(srvAddrs) {
+				d.warnf(findingSrvDistribution,
+					"The DNS SRV record `%s` does not spread clients across its targets: %s.  Give"+
+						" the targets the same priority and non-zero weights to distribute bootstrap load.",
+					connSpecSrv, issue)
+			}
+
+			// Replace the hosts for DNS testing with the values from the DNS SRV record
+			dnsHosts = []gocbconnstr.Address{}
+			for _, addr := range srvAddrs {
+				addrTarget := addr.Target
+				addrPort := int(addr.Port)
+
+				if !strings.HasSuffix(addrTarget, ".") {
+					d.warnf(findingSrvTrailingDot,
+						"The hostname specified in one of the SRV records was missing the trailing"+
+							" dot which is expected to make a valid SRV record entry.")
+				}
+
+				addrTarget = strings.TrimSuffix(addrTarget, ".")
+				d.redactHost(addrTarget)
+
+				targetAddrs, err := d.lookupHost(addrTarget)
+				if err != nil || len(targetAddrs) == 0 {
+					d.errorf(findingSrvStaleTarget,
+						"The DNS SRV record `%s` points at host `%s`, which does not resolve.  This"+
+							" usually means the SRV record is stale, for instance after nodes were removed"+
+							" from the cluster, and SDKs will fail to connect to this entry.",
+						connSpecSrv, addrTarget)
+				}
+				for _, targetAddr := range targetAddrs {
+					srvTargetAddrs[targetAddr] = true
+				}
+
+				dnsHosts = append(dnsHosts, gocbconnstr.Address{
+					Host: addrTarget,
+					Port: addrPort,
+				})
+			}
+		}
+
+		if len(srvAddrs) > 0 && len(aAddrs) > 0 {
+			// Compare the machines both record types point at, as an SDK which falls back
+			//  to the A records would otherwise end up talking to an entirely different set.
+			var sharedAddrs []string
+			for _, addr := range aAddrs {
+				if srvTargetAddrs[addr] {
+					sharedAddrs = append(sharedAddrs, addr)
+				}
+			}
+
+			if len(sharedAddrs) == 0 {
+				d.errorf(findingSrvAndARecords,
+					"The hostname specified in your connection string resolves both for SRV"+
+						" records, as well as A records, and they point at different machines (A"+
+						" records: %s).  Depending on which records an SDK uses, it will contact an"+
+						" entirely different set of servers.",
+					strings.Join(aAddrs, ", "))
+			} else {
+				d.warnf(findingSrvAndARecords,
+					"The hostname specified in your connection string resolves both for SRV"+
+						" records, as well as A records.  This is not suggested as later DNS"+
+						" configuration changes could cause the wrong servers to be contacted")
+			}
+		}
+	}
+
+	maxSeedHosts := d.opts.MaxSeedHosts
+	if maxSeedHosts <= 0 {
+		maxSeedHosts = DefaultMaxSeedHosts
+	}
+	if len(connSpec.Addresses) > maxSeedHosts {
+		d.log.Detail(
+			"Your connection string specifies %d hosts.  SDKs only need a few seed nodes to"+
+				" bootstrap, after which they learn the rest of the cluster from its configuration,"+
+				" and listing many nodes slows down bootstrap.  Consider listing 3 to 5 stable nodes"+
+				" instead, or using a DNS SRV record.",
+			len(connSpec.Addresses))
+	}
+
+	if warnSingleHost {
+		d.warnf(findingSingleHost,
+			"Your connection string specifies only a single host.  You should"+
+				" consider adding additional static nodes from your cluster to this"+
+				" list to improve your applications fault-tolerance")
+	}
+
+	dnsResolved := false
+	for _, target := range dnsHosts {
+		strippedHost := helpers.StripIPv6Brackets(target.Host)
+
+		if ip := net.ParseIP(strippedHost); ip != nil {
+			d.log.Log("Bootstrap host `%s` is an IP literal, skipping DNS resolution", strippedHost)
+			dnsResolved = true
+			if ip.To4() == nil {
+				d.log.Log(
+					"Bootstrap host `%s` is an IPv6 address. This is only supported in Couchbase"+
+						" Server 5.5 or later, and must be specifically enabled on the cluster.",
+					strippedHost)
+			}
+			continue
+		}
+
+		d.log.Log("Performing DNS lookup for host `%s`", strippedHost)
+
+		addrs, err := d.lookupHost(strippedHost)
+
+		if err != nil {
+			if dnsErr, ok := err.(*net.DNSError); ok {
+				if dnsErr.Err == "no such host" {
+					err = nil
+					addrs = nil
+				}
+			} else {
+				d.errorf(findingDNSLookupFailed,
+					"Failed to perform DNS lookup for bootstrap entry `%s` (error: %s)",
+					strippedHost, err)
+				continue
+			}
+		}
+
+		if err != nil || len(addrs) == 0 {
+			d.errorf(findingNoDNSEntry,
+				"Bootstrap host `%s` does not have a valid DNS entry.",
+				strippedHost)
+			continue
+		}
+
+		dnsResolved = true
+		if len(addrs) > 1 {
+			d.warnf(findingMultipleDNSEntries,
+				"Bootstrap host `%s` has more than one single DNS entry associated.  While this"+
+					" is not neccessarily an error, it has been known to cause difficult-to-diagnose"+
+					" problems in the future when routing is changed or the cluster layout is updated.",
+				strippedHost)
+		} else if addrs[0] != strippedHost {
+			d.log.Log(
+				"Bootstrap host `%s` refers to a server with the address `%s`",
+				strippedHost, addrs[0])
+		}
+
+		// Check for any IPv6 addresses
+		ips, _ := d.lookupIPAddr(strippedHost)
+
+		hasIPv6 := false
+		for _, ip := range ips {
+			if ip.IP.To4() == nil {
+				hasIPv6 = true
+			}
+		}
+		if hasIPv6 {
+			d.log.Log(
+				"Bootstrap host `%s` has IPv6 addresses associated. This is only supported"+
+					" in Couchbase Server 5.5 or later, and must be specifically enabled on"+
+					" the cluster.",
+				strippedHost)
+		}
+	}
+
+	//======================================================================
+	//  BOOTSTRAP
+	//======================================================================
+	if err := d.setPhase(phaseBootstrap); err != nil {
+		return err
+	}
+	d.setTimeout(d.bootstrapTimeout)
+	var nodesList []clusterNode
+	var bootstrapConfig *terseBucketConfig
+	var configSource string
+	var bootstrapFailures []bootstrapFailure
+	networkUnavailable := false
+	cccpAttempted := false
+	cccpSkipped := ""
+
+	selectedNetwork := connStrNetwork(connSpec)
+	if selectedNetwork != "" {
+		d.log.Log("Using the `%s` network, as requested by the connection string", selectedNetwork)
+	}
+
+	// Probe the management endpoints first, these tell reachability and auth problems apart
+	poolsProbe := d.probeManagementEndpoints(resConnSpec.HttpHosts, resConnSpec.UseSsl)
+
+	// Scans a list of hosts and fetch attempts and logs any appropriate warnings then returns
+	//  the first good configuration that was received (or nil if none are found).
+	scanTerseConfigList := func(hosts []gocbconnstr.Address, attempts []bootstrapAttempt) *terseBucketConfig {
+		if len(hosts) != len(attempts) {
+			panic(0)
+		}
+
+		masterIdx := firstSuccessfulAttempt(attempts)
+		if masterIdx == -1 {
+			return nil
+		}
+
+		masterConfig := attempts[masterIdx].Config
+
+		for i, target := range hosts {
+			config := attempts[i].Config
+
+			if config == nil {
+				continue
+			}
+
+			if i != masterIdx && config.UUID != masterConfig.UUID {
+				d.errorf(findingDifferentCluster,
+					"Boostrap host `%s` appears to be pointing to a different cluster.  Tests"+
+						" will be running against the first successfully connected node in your"+
+						" bootstrap list, as a client would behave.",
+					target.Host)
+			}
+
+			thisNodeExt := config.GetSourceNodeExt()
+			if thisNodeExt == nil {
+				d.addProxySignal(false, "the config from `%s` does not identify which node it came from", target.Host)
+			}
+			if thisNodes := config.thisNodeCount(); thisNodes > 1 {
+				d.warnf(findingMultipleThisNodes,
+					"The configuration from bootstrap host `%s` marks %d nodes as the node it came from"+
+						" (`thisNode`), rather than one.  This indicates a corrupted configuration, or one"+
+						" rewritten by a proxy, and clients may attribute it to the wrong node.",
+					target.Host, thisNodes)
+			}
+			if thisNodeExt != nil && thisNodeExt.Hostname != "" && target.Host != thisNodeExt.Hostname {
+				d.warnf(findingNonCanonicalHostname,
+					"Bootstrap host `%s` is not using the canonical node hostname of `%s`.  This"+
+						" is not neccessarily an error, but has been known to result in strange and"+
+						" challenging to diagnose errors when DNS entries are reconfigured.",
+					target.Host, thisNodeExt.Hostname)
+			}
+		}
+
+		// Hosts of another cluster are already reported, their revisions are unrelated
+		minIdx, maxIdx := configRevSpread(attempts)
+		if minIdx != -1 && attempts[minIdx].Config.UUID == attempts[maxIdx].Config.UUID &&
+			attempts[maxIdx].Config.Rev-attempts[minIdx].Config.Rev > revDivergenceThreshold {
+			d.warnf(findingConfigRevDivergence,
+				"The bootstrap hosts returned configurations with very different revisions (`%s:%d`"+
+					" at revision %d, `%s:%d` at revision %d).  The cluster is likely in the middle of"+
+					" a topology change, or some nodes hold a stale configuration, so the results of"+
+					" the doctor may be inconsistent.",
+				hosts[minIdx].Host, hosts[minIdx].Port, attempts[minIdx].Config.Rev,
+				hosts[maxIdx].Host, hosts[maxIdx].Port, attempts[maxIdx].Config.Rev)
+		}
+
+		d.log.Log("Using the first configuration received, which came from `%s:%d`",
+			hosts[masterIdx].Host, hosts[masterIdx].Port)
+
+		return masterConfig
+	}
+
+	// Builds the nodes list from a bootstrap configuration using the selected network
+	useTerseConfig := func(config *terseBucketConfig, source string) {
+		if selectedNetwork == "" {
+			selectedNetwork = networkFromTerseBucketConfig(*config)
+		}
+
+		nodesList = clusterNodesFromTerseBucketConfig(*config, selectedNetwork)
+		if nodesList == nil {
+			d.errorf(findingNetworkUnavailable,
+				"The `%s` network was selected, but not every node in the cluster advertises alternate"+
+					" addresses for it.  Check the `network` option of your connection string, and the"+
+					" alternate addresses configured on the cluster.",
+				selectedNetwork)
+			networkUnavailable = true
+			return
+		}
+
+		bootstrapConfig = config
+		configSource = source
+	}
+
+	// Attempt to bootstrap via CCCP
+	if nodesList == nil {
+		if len(resConnSpec.MemdHosts) == 0 {
+			d.log.Log("Not attempting CCCP, as the connection string does not support it")
+			cccpSkipped = "the connection string does not support CCCP"
+		} else if d.sdk != nil && !d.sdk.CCCP {
+			d.log.Log("Not attempting CCCP, as the %s does not support it", d.sdk)
+			cccpSkipped = fmt.Sprintf("the %s does not support CCCP", d.sdk)
+		} else {
+			d.log.Log("Attempting to connect to cluster via CCCP")
+			cccpAttempted = true
+
+			hosts := d.bootstrapHosts(resConnSpec.MemdHosts)
+			for _, target := range hosts {
+				d.log.Log("Attempting to fetch config via cccp from `%s:%d`", target.Host, target.Port)
+			}
+
+			attempts := d.fetchConfigs(hosts, func(host string, port int) (terseBucketConfig, error) {
+				return d.fetchCccpTerseBucketConfig(host, port, resConnSpec.Bucket)
+			})
+
+			for i, target := range hosts {
+				if attempts[i].Err != nil {
+					d.errorf(findingBootstrapEndpoint,
+						"Failed to fetch configuration via cccp from `%s:%d` (error: %s)",
+						target.Host, target.Port, attempts[i].Err.Error())
+					bootstrapFailures = append(bootstrapFailures, bootstrapFailure{
+						Method: "cccp",
+						Host:   target.Host,
+						Port:   target.Port,
+						Err:    attempts[i].Err,
+					})
+					d.checkPortProtocol(target.Host, target.Port, "cccp", protocolMemcached)
+					d.checkKVAuth(attempts[i].Err, target.Host, target.Port, resConnSpec.Bucket, poolsProbe)
+				}
+			}
+
+			masterConfig := scanTerseConfigList(hosts, attempts)
+			if masterConfig != nil {
+				useTerseConfig(masterConfig, "cccp")
+				d.printRawConfig("terse (cccp)", masterConfig.RawConfig)
+			}
+		}
+	}
+
+	// Attempt to bootstrap via Terse HTTP endpoints
+	if nodesList == nil && !networkUnavailable {
+		if len(resConnSpec.HttpHosts) == 0 {
+			d.log.Log("Not attempting HTTP (Terse), as the connection string does not support it")
+		} else if resConnSpec.Bucket == "" {
+			d.log.Log("Not attempting HTTP (Terse), as it requires a bucket")
+		} else if d.sdk != nil && !d.sdk.HTTP {
+			d.log.Log("Not attempting HTTP (Terse), as the %s does not support it", d.sdk)
+		} else {
+			d.log.Log("Attempting to connect to cluster via HTTP (Terse)")
+
+			hosts := d.bootstrapHosts(resConnSpec.HttpHosts)
+			for _, target := range hosts {
+				d.log.Log("Attempting to fetch terse config via http from `%s:%d`", target.Host, target.Port)
+			}
+
+			attempts := d.fetchConfigs(hosts, func(host string, port int) (terseBucketConfig, error) {
+				return d.fetchHTTPTerseBucketConfig(host, port, resConnSpec.Bucket, username, password)
+			})
+
+			for i, target := range hosts {
+				if attempts[i].Err != nil {
+					d.errorf(findingBootstrapEndpoint,
+						"Failed to fetch terse configuration via http from `%s:%d` (error: %s)",
+						target.Host, target.Port, attempts[i].Err.Error())
+					bootstrapFailures = append(bootstrapFailures, bootstrapFailure{
+						Method: "http",
+						Host:   target.Host,
+						Port:   target.Port,
+						Err:    attempts[i].Err,
+					})
+					d.checkPortProtocol(target.Host, target.Port, "http", protocolHTTP)
+					d.reportTLSRedirect(attempts[i].Err, resConnSpec.UseSsl)
+					d.reportUnresolvableRedirect(attempts[i].Err)
+					d.reportNotCouchbase(attempts[i].Err, target.Host, target.Port)
+				}
+			}
+
+			masterConfig := scanTerseConfigList(hosts, attempts)
+			if masterConfig != nil {
+				useTerseConfig(masterConfig, "http-terse")
+				d.printRawConfig("terse (http)", masterConfig.RawConfig)
+			}
+		}
+	}
+
+	// Attempt to bootstrap via full HTTP endpoints
+	if nodesList == nil && !networkUnavailable {
+		if len(resConnSpec.HttpHosts) == 0 {
+			d.log.Log("Not attempting HTTP (Full), as the connection string does not support it")
+		} else {
+			d.log.Log("Attempting to connect to cluster via HTTP (Full)")
+
+			// TODO: Add support for full HTTP configuration fetching
+
+			d.log.Log("Failed to connect via HTTP (Full), as it is not yet supported by the doctor")
+		}
+	}
+
+	// Whether the terse endpoint needs credentials tells whether HTTP bootstrap
+	//  says anything about them.
+	if poolsProbe != nil && poolsProbe.Reachable() && resConnSpec.Bucket != "" {
+		d.checkTerseAuth(poolsProbe.Host, poolsProbe.Port, resConnSpec.Bucket)
+	}
+
+	// Print out information about which network type was selected
+	d.log.Log("Selected the following network type: %s", selectedNetwork)
+
+	d.checkProxyNodes(nodesList)
+	d.reportProxySignals()
+
+	// Failed to bootstrap
+	if nodesList == nil {
+		if networkUnavailable {
+			return nil
+		}
+
+		if poolsProbe == nil && isCapella && dnsResolved {
+			d.reportCapellaConnectivity()
+		} else if (poolsProbe == nil || !poolsProbe.Reachable()) && d.checkTLSEnforced(resConnSpec) {
+			d.log.Log("Further cluster diagnostics are not possible without TLS")
+		} else if poolsProbe == nil {
+			d.errorf(findingEndpointsUnreachable,
+				"All endpoints specified by your connection string were unreachable, further"+
+					" cluster diagnostics are not possible")
+		} else if poolsProbe.AuthRejected() {
+			d.errorf(findingCredentialsRejected,
+				"The cluster is reachable at `%s:%d`, but rejected the provided credentials.  Check"+
+					" the username and password, further cluster diagnostics are not possible",
+				poolsProbe.Host, poolsProbe.Port)
+		} else if resConnSpec.Bucket == "" {
+			d.errorf(findingNoBucket,
+				"The cluster is reachable at `%s:%d`, but it does not provide a configuration without"+
+					" a bucket.  Specify a bucket, further cluster diagnostics are not possible",
+				poolsProbe.Host, poolsProbe.Port)
+		} else {
+			d.errorf(findingBucketUnavailable,
+				"The cluster is reachable at `%s:%d`, but the configuration for bucket `%s` could"+
+					" not be fetched.  Check that the bucket exists and that the user has access to"+
+					" it, further cluster diagnostics are not possible",
+				poolsProbe.Host, poolsProbe.Port, resConnSpec.Bucket)
+		}
+
+		if len(bootstrapFailures) > 0 {
+			d.errorf(findingBootstrapFailed,
+				"Bootstrap failed against each endpoint for the following reasons:\n%s",
+				formatBootstrapFailures(bootstrapFailures))
+		}
+
+		if d.opts.Continue {
+			//======================================================================
+			//  SEED PORTS
+			//======================================================================
+			if err := d.setPhase(phaseSeedPorts); err != nil {
+				return err
+			}
+			d.log.Log("Continuing past the bootstrap failure to check the ports of the seed hosts")
+			d.checkSeedPorts(resConnSpec)
+		}
+		return nil
+	}
+
+	if configSource == "cccp" {
+		d.bootstrapMethod = "CCCP"
+	} else {
+		d.bootstrapMethod = "HTTP (Terse)"
+		if cccpAttempted {
+			d.bootstrapFallback = "CCCP failed"
+		} else {
+			d.bootstrapFallback = cccpSkipped
+		}
+	}
+	d.log.Log("Bootstrapped via %s", d.bootstrapMethod)
+
+	if d.opts.ExpectUUID != "" {
+		if bootstrapConfig.UUID == "" {
+			d.warnf(findingNoClusterUUID,
+				"The cluster does not advertise its UUID, so it could not be checked against `%s`",
+				d.opts.ExpectUUID)
+		} else if !strings.EqualFold(bootstrapConfig.UUID, d.opts.ExpectUUID) {
+			d.errorf(findingUnexpectedCluster,
+				"Bootstrapped against the cluster with UUID `%s`, but `%s` was expected.  Your connection"+
+					" string points at a different cluster than intended, such as staging instead of"+
+					" production, further cluster diagnostics are skipped.",
+				bootstrapConfig.UUID, d.opts.ExpectUUID)
+			return nil
+		} else {
+			d.log.Log("Bootstrapped against the expected cluster with UUID `%s`", bootstrapConfig.UUID)
+		}
+	}
+
+	d.log.Log("Identified the following nodes:")
+	for i, target := range nodesList {
+		d.log.Log("  [%d] %s", i, target.Hostname)
+
+		serviceStr := ""
+		serviceNum := 0
+		for service, port := range target.Services {
+			if serviceStr != "" {
+				serviceStr += ", "
+			}
+
+			serviceStr += fmt.Sprintf("%20s:% 6d", service, port)
+
+			if serviceNum%3 == 2 {
+				d.log.Log("    %s", serviceStr)
+				serviceStr = ""
+			}
+
+			serviceNum++
+		}
+
+		if serviceStr != "" {
+			d.log.Log("    %s", serviceStr)
+		}
+	}
+
+	// SDKs which cannot use CCCP have no better path to be pointed at
+	if configSource != "cccp" && cccpAttempted {
+		d.errorf(findingCCCPFailed,
+			"Bootstrapping via CCCP failed, but succeeded via HTTP.  SDKs prefer CCCP and only fall"+
+				" back to HTTP after it fails, so your application will likely be slow to bootstrap or"+
+				" fail to bootstrap at all, even though the doctor could connect.  Fix the CCCP"+
+				" failures reported above.")
+	} else if configSource != "cccp" && (d.sdk == nil || d.sdk.CCCP) {
+		d.warnf(findingNonOptimalBootstrap,
+			"Your configuration was fetched via a non-optimal path, you should update your"+
+				" connection string and/or cluster configuration to allow CCCP config fetch")
+	}
+
+	svcCounts := serviceDistribution(nodesList)
+	d.log.Log("Identified the following service distribution (nodes per service): %s",
+		formatServiceDistribution(svcCounts))
+
+	seedHosts := seedHostNames(resConnSpec)
+	canonicalHosts := canonicalHostNames(nodesList)
+	d.nodeHosts = canonicalHosts
+	d.log.Log("Connection string seed hosts: %s, cluster canonical hostnames: %s",
+		formatHostList(seedHosts), formatHostList(canonicalHosts))
+
+	if len(canonicalHosts) > 0 && !anyHostIn(seedHosts, canonicalHosts) {
+		seedKind, canonicalKind := "hostnames", "hostnames"
+		if allIPs(seedHosts) {
+			seedKind = "IP addresses"
+		}
+		if allIPs(canonicalHosts) {
+			canonicalKind = "IP addresses"
+		}
+
+		d.warnf(findingNoCanonicalSeeds,
+			"None of the seed hosts in your connection string (%s, which are %s) are hostnames the cluster"+
+				" advertises for its nodes (%s, which are %s).  Clients bootstrap from the seed hosts, but then"+
+				" connect to the advertised hostnames, so both must be reachable and resolvable from the"+
+				" application servers.",
+			formatHostList(seedHosts), seedKind, formatHostList(canonicalHosts), canonicalKind)
+	}
+
+	for _, node := range nodesList {
+		if !node.hasServices() {
+			d.warnf(findingNodeNoServices,
+				"Node `%s` is part of the cluster configuration, but advertises no services at all."+
+					"  This happens during a rebalance or for a failed over node, and clients may"+
+					" still route requests to it, causing intermittent errors.",
+				node.Hostname)
+		}
+	}
+
+	for _, conflict := range serviceConflicts(nodesList) {
+		d.warnf(findingServiceConflict,
+			"The cluster configuration is inconsistent: %s.  This indicates a corrupt configuration"+
+				" or misapplied alternate addresses, and clients will connect to the wrong service.",
+			conflict)
+	}
+
+	if svcCounts["kv"] == 0 && resConnSpec.Bucket == "" {
+		d.errorf(findingNoKVNodes,
+			"None of the %d nodes of the cluster advertise the Key Value service.  SDKs will be"+
+				" unable to perform any data operations until a node with the Data service is added"+
+				" to the cluster.",
+			len(nodesList))
+	} else if svcCounts["kv"] == 0 {
+		d.errorf(findingNoKVNodes,
+			"None of the %d nodes serving bucket `%s` advertise the Key Value service.  SDKs"+
+				" will be unable to perform any data operations against this bucket until a"+
+				" node with the Data service is added to the cluster.",
+			len(nodesList), resConnSpec.Bucket)
+	} else if d.tlsConfig != nil {
+		var noKVSSLHosts []string
+		for _, node := range nodesList {
+			if node.Services["kv"] != 0 && node.Services["kvSSL"] == 0 {
+				noKVSSLHosts = append(noKVSSLHosts, node.Hostname)
+			}
+		}
+
+		if svcCounts["kvSSL"] == 0 && resConnSpec.Bucket == "" {
+			d.errorf(findingNoKVSSL,
+				"None of the nodes of the cluster advertise the encrypted Key Value port (kvSSL)."+
+					"  Management requests over TLS work, but SDKs using secured connections will be"+
+					" unable to perform any data operations.")
+		} else if svcCounts["kvSSL"] == 0 {
+			d.errorf(findingNoKVSSL,
+				"None of the nodes serving bucket `%s` advertise the encrypted Key Value port"+
+					" (kvSSL).  Management requests over TLS work, but SDKs using secured connections"+
+					" will be unable to perform any data operations against this bucket.",
+				resConnSpec.Bucket)
+		} else if len(noKVSSLHosts) > 0 {
+			d.errorf(findingNoKVSSL,
+				"Nodes %s run the Key Value service, but do not advertise its encrypted port (kvSSL)."+
+					"  SDKs using secured connections will fail data operations for the vBuckets these"+
+					" nodes host.",
+				formatHostList(noKVSSLHosts))
+		}
+	}
+
+	var nonDefaultPorts []string
+	for _, node := range nodesList {
+		for _, port := range nonDefaultServicePorts(node) {
+			nonDefaultPorts = append(nonDefaultPorts, fmt.Sprintf("`%s` %s", node.Hostname, port))
+		}
+	}
+	if len(nonDefaultPorts) > 0 {
+		d.log.Detail(
+			"Some services are advertised on non-standard ports, make sure your firewall rules"+
+				" allow access to them: %s",
+			strings.Join(nonDefaultPorts, ", "))
+	}
+
+	//======================================================================
+	//  CLUSTER INFORMATION
+	//======================================================================
+	if err := d.setPhase(phaseClusterInfo); err != nil {
+		return err
+	}
+	var infoSourceTarget *clusterNode
+	var clusterInfo *clusterConfig
+
+	infoSourceSvcKey := "mgmt"
+	infoSourceScheme := "http"
+	if d.tlsConfig != nil {
+		infoSourceSvcKey = "mgmtSSL"
+		infoSourceScheme = "https"
+	}
+
+	for _, target := range nodesList {
+		if target.Services[infoSourceSvcKey] != 0 {
+			infoSourceTarget = &target
+			break
+		}
+	}
+
+	{
+		if infoSourceTarget == nil {
+			d.log.Log("Failed to retrieve cluster information as we couldn't find a node with management services")
+		} else {
+			infoSourceHost := infoSourceTarget.Hostname
+			infoSourcePort := infoSourceTarget.Services[infoSourceSvcKey]
+
+			d.log.Log("Fetching config from `%s://%s:%d`",
+				infoSourceScheme,
+				infoSourceHost,
+				infoSourcePort)
+
+			uri := fmt.Sprintf("%s://%s/pools/default", infoSourceScheme, helpers.JoinHostPort(infoSourceHost, infoSourcePort))
+			req, _ := http.NewRequest("GET", uri, nil)
+			req.SetBasicAuth(username, password)
+
+			resp, _, err := d.doHTTP(req)
+			if err != nil {
+				d.log.Log("Failed to retreive cluster information (error: %s)", err.Error())
+			} else if resp.StatusCode != 200 {
+				closeResponse(resp)
+				d.log.Log("Failed to retreive cluster information (status code: %d)", resp.StatusCode)
+			} else {
+				configBytes, err := readConfigBody(resp.Body)
+				closeResponse(resp)
+
+				var rawClusterConfig map[string]interface{}
+				if err == nil {
+					err = unmarshalConfig(configBytes, &rawClusterConfig)
+				}
+
+				if err != nil {
+					d.warnf(findingClusterInfoFailed, "Failed to read cluster information (error: %s)",
+						err.Error())
+				} else {
+					var config clusterConfig
+					if json.Unmarshal(configBytes, &config) == nil {
+						d.redactClusterHosts(config)
+						clusterInfo = &config
+					}
+
+					fmtdConfigNodes, _ := json.MarshalIndent(rawClusterConfig["nodes"], "", "  ")
+					d.log.Log("Received cluster configuration, nodes list:\n%s", fmtdConfigNodes)
+				}
+			}
+		}
+	}
+
+	if clusterInfo != nil && bootstrapConfig != nil && resConnSpec.Bucket != "" {
+		clusterOnly, bucketOnly := clusterNodeDifference(*bootstrapConfig, *clusterInfo)
+		if len(clusterOnly) > 0 || len(bucketOnly) > 0 {
+			d.warnf(findingBucketNodesMismatch,
+				"The cluster configuration and the configuration of bucket `%s` list different nodes"+
+					" (cluster only: %s; bucket only: %s).  The bucket is not distributed across the"+
+					" whole cluster, or the cluster is changing, which affects which nodes clients can"+
+					" read from and write to.",
+				resConnSpec.Bucket, formatHostList(clusterOnly), formatHostList(bucketOnly))
+		} else {
+			d.log.Log("The cluster configuration and the configuration of bucket `%s` list the same %d nodes",
+				resConnSpec.Bucket, len(clusterInfo.Nodes))
+		}
+	}
+
+	if clusterInfo != nil {
+		for _, node := range clusterInfo.Nodes {
+			if node.Status != "" && node.Status != "healthy" {
+				d.warnf(findingNodeUnhealthy,
+					"Node `%s` reports a status of `%s` rather than `healthy`.  Operations against this"+
+						" node may fail until it recovers, and the results of the doctor may be unreliable"+
+						" while it does.",
+					node.Hostname, node.Status)
+			}
+		}
+
+		compat := clusterInfo.CompatVersion()
+		if compat == 0 {
+			d.log.Log("Cluster does not advertise a compatibility version")
+		} else {
+			d.log.Detail("Cluster compatibility version is %s", formatCompatVersion(compat))
+
+			checkCompat := func(feature string, major, minor int) {
+				if compat < makeCompatVersion(major, minor) {
+					d.warnf(findingCompatVersion,
+						"%s requires a cluster compatibility version of %d.%d, but the cluster is at %s."+
+							"  The cluster only runs at the feature level of its oldest node, so new features"+
+							" stay disabled until every node has been upgraded.",
+						feature, major, minor, formatCompatVersion(compat))
+				}
+			}
+
+			if scope != "" || collection != "" {
+				checkCompat("Using scopes and collections", 7, 0)
+			}
+			if d.opts.Durability != "" && d.opts.Durability != "none" {
+				checkCompat("Using durable writes", 6, 5)
+			}
+		}
+	}
+
+	//======================================================================
+	//  BUCKET INFORMATION
+	//======================================================================
+	if err := d.setPhase(phaseBucketInfo); err != nil {
+		return err
+	}
+
+	var bucketInfo *bucketConfig
+	if infoSourceTarget == nil {
+		d.log.Log("Failed to retrieve bucket information as we couldn't find a node with management services")
+	} else if resConnSpec.Bucket == "" {
+		d.log.Log("Skipping bucket information, as no bucket was specified")
+	} else {
+		config, err := d.fetchBucketConfig(infoSourceScheme, infoSourceTarget.Hostname,
+			infoSourceTarget.Services[infoSourceSvcKey], resConnSpec.Bucket, username, password)
+		d.checkLargeResponse(infoSourceTarget.Hostname, infoSourceTarget.Services[infoSourceSvcKey],
+			len(config.RawConfig), err, poolsProbe)
+		if err != nil {
+			d.warnf(findingBucketInfoFailed, "Failed to retrieve information about bucket `%s` (error: %s)",
+				resConnSpec.Bucket, err.Error())
+		} else {
+			bucketInfo = &config
+			d.printRawConfig("full bucket", config.RawConfig)
+		}
+	}
+
+	if bucketInfo != nil {
+		d.log.Detail("Bucket `%s` is a %s bucket with %d replicas",
+			resConnSpec.Bucket, bucketInfo.TypeName(), bucketInfo.ReplicaNumber)
+
+		// Each replica must live on a different node than the active copy
+		if bucketInfo.ReplicaNumber > 0 && svcCounts["kv"] > 0 && svcCounts["kv"] <= bucketInfo.ReplicaNumber {
+			d.warnf(findingReplicasUnsatisfiable,
+				"Bucket `%s` is configured with %d replicas, but the cluster only has %d nodes running"+
+					" the Data service, so not every replica can be placed.  Durable writes and replica"+
+					" reads will fail against this bucket.",
+				resConnSpec.Bucket, bucketInfo.ReplicaNumber, svcCounts["kv"])
+		}
+
+		if bucketInfo.EvictionPolicy != "" {
+			d.log.Detail("Bucket `%s` uses the `%s` ejection policy", resConnSpec.Bucket, bucketInfo.EvictionPolicy)
+		}
+
+		supportsDurability := bucketInfo.HasCapability("durableWrite")
+		if supportsDurability {
+			minLevel := bucketInfo.DurabilityMinLevel
+			if minLevel == "" {
+				minLevel = "none"
+			}
+			d.log.Detail("Bucket `%s` supports durable writes (minimum durability level: %s)",
+				resConnSpec.Bucket, minLevel)
+		} else {
+			d.log.Detail("Bucket `%s` does not support durable writes", resConnSpec.Bucket)
+		}
+
+		if d.opts.Durability != "" && d.opts.Durability != "none" {
+			if !supportsDurability {
+				d.errorf(findingDurabilityUnsupported,
+					"Durability level `%s` was requested, but bucket `%s` does not support durable"+
+						" writes.  Durable writes require Couchbase Server 6.5 or later.",
+					d.opts.Durability, resConnSpec.Bucket)
+			} else if bucketInfo.BucketType == "ephemeral" && d.opts.Durability != "majority" {
+				d.errorf(findingDurabilityUnsupported,
+					"Durability level `%s` was requested, but bucket `%s` is an ephemeral bucket,"+
+						" which only supports the `majority` durability level.",
+					d.opts.Durability, resConnSpec.Bucket)
+			}
+		}
+
+		durableWrites := (d.opts.Durability != "" && d.opts.Durability != "none") ||
+			(bucketInfo.DurabilityMinLevel != "" && bucketInfo.DurabilityMinLevel != "none")
+		if supportsDurability && durableWrites {
+			d.checkDurabilityQuorum(resConnSpec.Bucket, bucketInfo.ReplicaNumber, svcCounts["kv"])
+		}
+	}
+
+	//======================================================================
+	//  ALL BUCKETS
+	//======================================================================
+	if d.opts.AllBuckets {
+		if err := d.setPhase(phaseAllBuckets); err != nil {
+			return err
+		}
+		d.checkAllBuckets(nodesList, infoSourceScheme, infoSourceTarget, infoSourceSvcKey)
+	}
+
+	//======================================================================
+	//  COLLECTIONS
+	//======================================================================
+	if err := d.setPhase(phaseCollections); err != nil {
+		return err
+	}
+	if collection != "" && scope == "" {
+		scope = "_default"
+	}
+
+	if infoSourceTarget == nil {
+		d.log.Log("Failed to check for collections support as we couldn't find a node with management services")
+	} else if resConnSpec.Bucket == "" {
+		d.log.Log("Skipping the collections check, as no bucket was specified")
+	} else {
+		infoSourceHost := infoSourceTarget.Hostname
+		infoSourcePort := infoSourceTarget.Services[infoSourceSvcKey]
+
+		d.log.Log("Fetching collection manifest for bucket `%s` from `%s://%s:%d`",
+			resConnSpec.Bucket,
+			infoSourceScheme,
+			infoSourceHost,
+			infoSourcePort)
+
+		manifest, err := d.fetchCollectionManifest(infoSourceScheme, infoSourceHost, infoSourcePort,
+			resConnSpec.Bucket, username, password)
+
+		// A missing manifest only means that collections are unsupported if the bucket exists
+		bucketFound := bucketInfo != nil || bootstrapConfig != nil
+		if err == errManifestNotFound && !bucketFound {
+			d.warnf(findingManifestFailed,
+				"Failed to fetch the collection manifest, as bucket `%s` was not found.  Check that"+
+					" the bucket exists, and that the credentials may access it.",
+				resConnSpec.Bucket)
+		} else if err == errManifestNotFound && bucketInfo != nil && bucketInfo.HasCapability("collections") {
+			d.warnf(findingManifestFailed,
+				"Failed to fetch the collection manifest, although bucket `%s` supports collections (error: %s)",
+				resConnSpec.Bucket, err.Error())
+		} else if err == errManifestNotFound {
+			d.log.Log("Cluster does not support collections (requires Couchbase Server 7.0 or later)")
+
+			if scope != "" {
+				d.errorf(findingCollectionsUnsupported,
+					"Scope `%s` was specified, but the cluster does not support collections.  Only the"+
+						" default collection can be used against this cluster.",
+					scope)
+			}
+		} else if err != nil {
+			d.warnf(findingManifestFailed, "Failed to fetch the collection manifest (error: %s)", err.Error())
+		} else {
+			d.log.Log("Cluster supports collections")
+			d.log.Detail("Collection manifest UID: %s", manifest.UID)
+
+			if scope != "" {
+				manifestScope := manifest.GetScope(scope)
+				if manifestScope == nil {
+					d.errorf(findingScopeMissing, "Scope `%s` does not exist in bucket `%s`", scope, resConnSpec.Bucket)
+				} else {
+					d.log.Log("Scope `%s` exists in bucket `%s`", scope, resConnSpec.Bucket)
+
+					if collection != "" {
+						if manifestScope.GetCollection(collection) == nil {
+							d.errorf(findingCollectionMissing, "Collection `%s` does not exist in scope `%s` of bucket `%s`",
+								collection, scope, resConnSpec.Bucket)
+						} else {
+							d.log.Log("Collection `%s` exists in scope `%s` of bucket `%s`",
+								collection, scope, resConnSpec.Bucket)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	//======================================================================
+	//  SERVICES
+	//======================================================================
+	if err := d.setPhase(phaseServices); err != nil {
+		return err
+	}
+	d.setTimeout(d.probeTimeout)
+
+	reachability := make(serviceReachability)
+
+	testMemdService := func(node clusterNode, svcName, svcKeyPlain, svcKeySSL string) {
+		svcKey := svcKeyPlain
+		if d.tlsConfig != nil {
+			svcKey = svcKeySSL
+		}
+
+		svcPort := node.Services[svcKey]
+		if svcPort != 0 {
+			client, err := d.dialMemd(node.Hostname, svcPort, resConnSpec.Bucket)
+			if err != nil {
+				msg := fmt.Sprintf("Failed to connect to %s service at `%s:%d` (error: %s)",
+					svcName, node.Hostname, node.Services[svcKey], err.Error())
+				if hint := connectErrorHint(err); hint != "" {
+					msg += ".  " + hint
+				}
+				d.errorf(findingServiceUnreachable, "%s", msg)
+				if isCapella {
+					d.reportCapellaConnectivity()
+				}
+				d.checkKVAuth(err, node.Hostname, svcPort, resConnSpec.Bucket, poolsProbe)
+				reachability.set(node.Hostname, svcKey, false)
+			} else {
+				reachability.set(node.Hostname, svcKey, true)
+				d.log.Log("Successfully connected to %s service at `%s:%d` from `%s`",
+					svcName, node.Hostname, node.Services[svcKey], client.LocalAddr())
+
+				d.reportTLSState(svcName, node.Hostname, svcPort, client.TLSConnectionState())
+				d.checkClientAddress(client.LocalAddr(), client.RemoteAddr(), node.Hostname, svcPort)
+				d.reportSASLMechanism(client, node.Hostname, svcPort)
+				d.reportErrorMap(client, node.Hostname, svcPort)
+				d.reportHelloFeatures(client, node.Hostname, svcPort)
+
+				client.Close()
+			}
+		} else {
+			d.warnf(findingServiceNotAdvertised,
+				"Could not test %s service on `%s` as it was not in the config",
+				svcName, node.Hostname)
+		}
+	}
+
+	testHTTPService := func(node clusterNode, svcName, svcKeyPlain, svcKeySSL string) {
+		svcScheme := "http"
+		svcKey := svcKeyPlain
+		if d.tlsConfig != nil {
+			svcScheme = "https"
+			svcKey = svcKeySSL
+		}
+
+		svcPort := node.Services[svcKey]
+		if svcPort != 0 {
+			uri := fmt.Sprintf("%s://%s/", svcScheme, helpers.JoinHostPort(node.Hostname, svcPort))
+			req, _ := http.NewRequest("GET", uri, nil)
+			// No credentials are set here since we only care that the service responds,
+			//  and the management service redirects to its admin UI without them.
+
+			resp, localAddr, err := d.doHTTP(req)
+			if err != nil {
+				msg := fmt.Sprintf("Failed to connect to %s service at `%s:%d` (error: %s)",
+					svcName, node.Hostname, node.Services[svcKey], err.Error())
+				if hint := connectErrorHint(err); hint != "" {
+					msg += ".  " + hint
+				}
+				d.errorf(findingServiceUnreachable, "%s", msg)
+				if isCapella {
+					d.reportCapellaConnectivity()
+				}
+				reachability.set(node.Hostname, svcKey, false)
+			} else {
+				closeResponse(resp)
+				reachability.set(node.Hostname, svcKey, true)
+
+				// Any response proves the port is open, only the admin UI proves the
+				//  management service is the one listening on it.
+				if svcKeyPlain != "mgmt" || d.checkAdminUI(resp, node.Hostname, svcPort) {
+					d.log.Log("Successfully connected to %s service at `%s:%d` from `%s`",
+						svcName, node.Hostname, node.Services[svcKey], localAddr)
+				}
+
+				d.reportTLSState(svcName, node.Hostname, svcPort, resp.TLS)
+			}
+		} else {
+			d.warnf(findingServiceNotAdvertised,
+				"Could not test %s service on `%s` as it was not in the config",
+				svcName, node.Hostname)
+		}
+	}
+
+	for _, node := range nodesList {
+		if !node.hasServices() {
+			d.log.Log("Not testing the services of node `%s`, as it advertises none", node.Hostname)
+			continue
+		}
+
+		testMemdService(node, "Key Value", "kv", "kvSSL")
+		testHTTPService(node, "Management", "mgmt", "mgmtSSL")
+		if d.tlsConfig != nil && reachability[node.Hostname]["mgmtSSL"] {
+			d.reportHTTPVersion(node.Hostname, node.Services["mgmtSSL"])
+		}
+		testHTTPService(node, "Views", "capi", "capiSSL")
+		testHTTPService(node, "Query", "n1ql", "n1qlSSL")
+		testHTTPService(node, "Search", "fts", "ftsSSL")
+		testHTTPService(node, "Analytics", "cbas", "cbasSSL")
+
+		// The index service only runs on some nodes, its HTTP admin API tells an
+		//  unreachable indexer apart from problems of the query service itself.
+		if node.Services["indexHttp"] != 0 || node.Services["indexHttps"] != 0 {
+			testHTTPService(node, "Index", "indexHttp", "indexHttps")
+		}
+
+		// The backup service only runs on some nodes of 7.0+ clusters
+		if node.Services["backupAPI"] != 0 || node.Services["backupAPIHTTPS"] != 0 {
+			testHTTPService(node, "Backup", "backupAPI", "backupAPIHTTPS")
+		}
+	}
+
+	if clusterInfo != nil {
+		d.checkCouchAPIBases(clusterInfo, nodesList)
+	}
+
+	if d.opts.QueryNodes {
+		d.checkQueryNodes(nodesList)
+	}
+
+	if d.opts.QueryKeyspaces {
+		d.checkQueryKeyspaces(nodesList)
+	}
+
+	if d.opts.ListEndpoints {
+		d.endpoints = discoveredEndpoints(nodesList, reachability)
+	}
+
+	if d.opts.TopologyOutput != nil {
+		err := writeTopologyDot(d.opts.TopologyOutput, nodesList, reachability, d.tlsConfig != nil)
+		if err != nil {
+			d.warnf(findingOutputFailed, "Failed to write the topology diagram (error: %s)", err.Error())
+		}
+	}
+
+	//======================================================================
+	//  CONNECTION PERFORMANCE
+	//======================================================================
+	if err := d.setPhase(phasePerformance); err != nil {
+		return err
+	}
+	pingCount := d.opts.PingCount
+	if pingCount <= 0 {
+		pingCount = DefaultPingCount
+	}
+	for _, node := range nodesList {
+		kvPort := node.Services["kv"]
+		if d.tlsConfig != nil {
+			kvPort = node.Services["kvSSL"]
+		}
+
+		if kvPort != 0 {
+			client, err := d.dialMemd(node.Hostname, kvPort, resConnSpec.Bucket)
+			if err != nil {
+				d.warnf(findingPerformanceUnavailable,
+					"Failed to perform KV connection performance analysis on `%s:%d` (error: %s)",
+					node.Hostname, kvPort, err.Error())
+				continue
+			}
+
+			var stats helpers.PingHelper
+
+			for i := 0; i < pingCount; i++ {
+				pingState := stats.StartOne()
+				err = client.Ping()
+				stats.StopOne(pingState, err)
+			}
+			client.Close()
+
+			d.log.Log("Memd Nop Pinged `%s:%d` %d times, %d errors, %dms min, %dms max, %dms mean, %dms p95",
+				node.Hostname, kvPort,
+				stats.Count(), stats.Errors(),
+				stats.Min()/time.Millisecond,
+				stats.Max()/time.Millisecond,
+				stats.Mean()/time.Millisecond,
+				stats.Percentile(95)/time.Millisecond)
+
+			if stats.Successes() == 0 {
+				continue
+			}
+
+			d.latencies = append(d.latencies, KVLatency{
+				Host: node.Hostname,
+				Port: kvPort,
+				Mean: stats.Mean(),
+				P95:  stats.Percentile(95),
+			})
+
+			d.log.Detail("KV latency to `%s:%d` over %d pings: %s min, %s avg, %s p95, %s max",
+				node.Hostname, kvPort, stats.Successes(),
+				stats.Min().Round(time.Microsecond),
+				stats.Mean().Round(time.Microsecond),
+				stats.Percentile(95).Round(time.Microsecond),
+				stats.Max().Round(time.Microsecond))
+
+			allowedMeanMs := 10
+			if stats.Mean() >= time.Duration(allowedMeanMs)*time.Millisecond {
+				d.warnf(findingSlowKV,
+					"Memcached service on `%s:%d` on average took longer than %dms (was: %dms) to"+
+						" reply.  This is usually due to network-related issues, and could significantly"+
+						" affect application performance.",
+					node.Hostname, kvPort,
+					allowedMeanMs, stats.Mean()/time.Millisecond)
+			}
+
+			allowedP95Ms := 15
+			if stats.Percentile(95) >= time.Duration(allowedP95Ms)*time.Millisecond {
+				d.warnf(findingSlowKV,
+					"Memcached service on `%s:%d` took longer than %dms (was: %dms) to reply to 5%% of"+
+						" pings.  This tail latency is usually due to network jitter, SDK operation"+
+						" timeouts should leave a comfortable margin above it.",
+					node.Hostname, kvPort,
+					allowedP95Ms, stats.Percentile(95)/time.Millisecond)
+			}
+
+			allowedMaxMs := 20
+			if stats.Max() >= time.Duration(allowedMaxMs)*time.Millisecond {
+				d.warnf(findingSlowKV,
+					"Memcached service on `%s:%d` maximally took longer than %dms (was: %dms) to reply."+
+						" This is usually due to network-related issues, and could significantly"+
+						" affect application performance.",
+					node.Hostname, kvPort,
+					allowedMaxMs, stats.Max()/time.Millisecond)
+			}
+		}
+	}
+
+	//======================================================================
+	//  IDLE CONNECTION
+	//======================================================================
+	if d.opts.IdleTest > 0 {
+		if err := d.setPhase(phaseIdle); err != nil {
+			return err
+		}
+
+		d.testIdleConnection(nodesList, resConnSpec.Bucket)
+	}
+
+	return nil
+}
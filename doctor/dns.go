@@ -0,0 +1,89 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// newResolver creates a resolver sending all queries to server, which is an
+// address with an optional port, defaulting to 53.
+func newResolver(server string, dialer *net.Dialer) *net.Resolver {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, strconv.Itoa(53))
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// lookupHost resolves host, bounded by the DNS timeout
+func (d *diagnoser) lookupHost(host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(d.ctx, d.dnsTimeout)
+	defer cancel()
+	return d.resolver.LookupHost(ctx, host)
+}
+
+// lookupIPAddr resolves the IP addresses of host, bounded by the DNS timeout
+func (d *diagnoser) lookupIPAddr(host string) ([]net.IPAddr, error) {
+	ctx, cancel := context.WithTimeout(d.ctx, d.dnsTimeout)
+	defer cancel()
+	return d.resolver.LookupIPAddr(ctx, host)
+}
+
+// lookupSRV resolves the SRV records of name, bounded by the DNS timeout
+func (d *diagnoser) lookupSRV(name string) ([]*net.SRV, error) {
+	ctx, cancel := context.WithTimeout(d.ctx, d.dnsTimeout)
+	defer cancel()
+	_, addrs, err := d.resolver.LookupSRV(ctx, "", "", name)
+	return addrs, err
+}
+
+// srvRecordIssues describes how the priorities and weights of SRV records
+// defeat spreading clients across their targets.  Clients only use the targets
+// with the lowest priority value, and pick among those by weight.
+func srvRecordIssues(addrs []*net.SRV) []string {
+	if len(addrs) < 2 {
+		return nil
+	}
+
+	var out []string
+
+	allZero := true
+	for _, addr := range addrs {
+		if addr.Weight != 0 {
+			allZero = false
+		}
+	}
+	if allZero {
+		out = append(out, fmt.Sprintf("all %d records have a weight of 0, which leaves how load is"+
+			" spread across them up to each client", len(addrs)))
+	}
+
+	lowest := addrs[0].Priority
+	for _, addr := range addrs {
+		if addr.Priority < lowest {
+			lowest = addr.Priority
+		}
+	}
+
+	var preferred []string
+	for _, addr := range addrs {
+		if addr.Priority == lowest {
+			preferred = append(preferred, strings.TrimSuffix(addr.Target, "."))
+		}
+	}
+	if len(preferred) == 1 {
+		out = append(out, fmt.Sprintf("only `%s` has the highest priority (%d), so every client"+
+			" bootstraps from it and only falls back to the other %d records when it is down",
+			preferred[0], lowest, len(addrs)-1))
+	}
+
+	return out
+}
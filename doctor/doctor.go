@@ -0,0 +1,502 @@
+// Package doctor performs app-server-side connection diagnostics against a
+// Couchbase cluster and reports its findings.
+package doctor
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// DefaultConnStr is the connection string used when none is specified
+const DefaultConnStr = "couchbase://localhost"
+
+// DefaultMaxSeedHosts is the number of bootstrap hosts above which the
+// connection string is considered to list too many of them
+const DefaultMaxSeedHosts = 5
+
+// DefaultConnectTimeout bounds how long establishing a connection may take
+const DefaultConnectTimeout = 2000 * time.Millisecond
+
+// DefaultTimeout bounds how long a request may take overall
+const DefaultTimeout = 2000 * time.Millisecond
+
+// DefaultPingCount is the number of NOOPs sent to each KV node to measure latency
+const DefaultPingCount = 10
+
+// Names of the phases diagnostics are performed in
+const (
+	phaseSelfTest    = "Self Test"
+	phaseConnStr     = "Connection String"
+	phaseSSL         = "SSL"
+	phaseCredentials = "Credentials"
+	phaseDNS         = "DNS"
+	phaseBootstrap   = "Bootstrap"
+	phaseSeedPorts   = "Seed Ports"
+	phaseClusterInfo = "Cluster Information"
+	phaseBucketInfo  = "Bucket Information"
+	phaseAllBuckets  = "All Buckets"
+	phaseCollections = "Collections"
+	phaseServices    = "Services"
+	phasePerformance = "Connection Performance"
+	phaseIdle        = "Idle Connection"
+)
+
+// allPhases lists every phase in the order they run
+var allPhases = []string{
+	phaseSelfTest,
+	phaseConnStr,
+	phaseSSL,
+	phaseCredentials,
+	phaseDNS,
+	phaseBootstrap,
+	phaseSeedPorts,
+	phaseClusterInfo,
+	phaseBucketInfo,
+	phaseAllBuckets,
+	phaseCollections,
+	phaseServices,
+	phasePerformance,
+	phaseIdle,
+}
+
+// Options specifies what to diagnose and how
+type Options struct {
+	// Label annotates the report, to tell the reports of several runs apart
+	Label string
+
+	// ConnStr is the connection string to diagnose, DefaultConnStr is used if empty
+	ConnStr  string
+	Username string
+	Password string
+
+	// Bucket is the bucket to diagnose when the connection string does not name one
+	Bucket string
+
+	// ExpectUUID is the UUID of the cluster the connection string is expected to
+	// point at, which is checked after bootstrapping when specified
+	ExpectUUID string
+
+	// SDK is the SDK and version the application uses, as `name/version`, to
+	// adjust the checks to its behavior, a generic current SDK is assumed if empty
+	SDK string
+
+	// Scope and Collection are verified to exist when specified (7.0+)
+	Scope      string
+	Collection string
+
+	// Durability is the durability level the application uses for writes,
+	// which is checked against what the bucket supports
+	Durability string
+
+	// TLSConfig is used for secured connections, server certificate
+	// verification is skipped if it is nil
+	TLSConfig *tls.Config
+
+	// TLSServerName overrides the server name presented and verified by TLS
+	// handshakes, which is the hostname connected to if empty
+	TLSServerName string
+
+	// IdleTest enables holding a KV connection open for up to this long to
+	// detect intermediaries dropping idle connections
+	IdleTest time.Duration
+
+	// MaxHosts limits how many bootstrap hosts are attempted, all of them
+	// are attempted if it is 0
+	MaxHosts int
+
+	// MaxSeedHosts is the number of bootstrap hosts above which the connection
+	// string is reported as listing too many, DefaultMaxSeedHosts is used if 0
+	MaxSeedHosts int
+
+	// MgmtPort overrides the management port used to bootstrap against hosts
+	// the connection string lists without a port, the default port is used if 0
+	MgmtPort int
+
+	// AllBuckets enables checking that every bucket of the cluster can be
+	// opened, which requires administrator credentials
+	AllBuckets bool
+
+	// ListEndpoints enables including every service endpoint the cluster
+	// advertises in the report
+	ListEndpoints bool
+
+	// QueryNodes enables asking each query node which query nodes it sees,
+	// to detect query services with a stale view of the cluster
+	QueryNodes bool
+
+	// QueryKeyspaces enables querying `system:keyspaces` on each query node, to
+	// detect query services which cannot see the cluster's metadata
+	QueryKeyspaces bool
+
+	// ConnectTimeout bounds how long establishing a connection may take,
+	// DefaultConnectTimeout is used if 0
+	ConnectTimeout time.Duration
+
+	// Timeout bounds how long a request may take overall, including
+	// connecting and reading the response, DefaultTimeout is used if 0
+	Timeout time.Duration
+
+	// DNSTimeout bounds how long each DNS lookup may take, BootstrapTimeout
+	// how long each request made to bootstrap and to fetch cluster and bucket
+	// information may take, and ProbeTimeout how long each request probing the
+	// services of the cluster's nodes may take.  Timeout is used for any of
+	// them which is 0.
+	DNSTimeout       time.Duration
+	BootstrapTimeout time.Duration
+	ProbeTimeout     time.Duration
+
+	// Continue enables checking the ports of the seed hosts when bootstrapping
+	// fails, instead of stopping there
+	Continue bool
+
+	// PingCount is the number of NOOPs sent to each KV node to measure its
+	// latency, DefaultPingCount is used if 0
+	PingCount int
+
+	// Redact enables replacing hostnames, IP addresses and bucket names with
+	// stable pseudonyms throughout the log, the report and the written configs
+	Redact bool
+
+	// LocalAddr is the local IP address to make all connections from, the
+	// operating system chooses one if empty
+	LocalAddr string
+
+	// TraceHTTP enables logging every HTTP request and response the doctor
+	// makes, with credentials redacted
+	TraceHTTP bool
+
+	// NoSuggestions disables attaching remediation hints to reported problems
+	NoSuggestions bool
+
+	// SelfTest enables checking the local environment (DNS, clock, outbound
+	// connectivity and proxies) before the cluster is diagnosed
+	SelfTest bool
+
+	// DNSServer is the address of a DNS server, with an optional port, to
+	// perform all lookups against instead of the system resolver
+	DNSServer string
+
+	// SOCKS5 is the address of a SOCKS5 proxy, as `[user:password@]host:port`,
+	// to make all connections through
+	SOCKS5 string
+
+	// ConfigOutput receives the raw configs that were fetched, pretty-printed,
+	// they are not written anywhere if it is nil
+	ConfigOutput io.Writer
+
+	// TopologyOutput receives a Graphviz DOT diagram of the cluster's nodes and
+	// which of their services could be reached, it is not written if nil
+	TopologyOutput io.Writer
+
+	// Output receives the step-by-step log as diagnostics run, it is
+	// discarded if nil
+	Output io.Writer
+}
+
+// contextDialer establishes the network connections of a run, either
+// directly or through a proxy
+type contextDialer interface {
+	Dial(network, address string) (net.Conn, error)
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// boundDialer makes plain dials of a run's dialer honor the run's context
+type boundDialer struct {
+	contextDialer
+	ctx context.Context
+}
+
+func (dialer boundDialer) Dial(network, address string) (net.Conn, error) {
+	return dialer.DialContext(dialer.ctx, network, address)
+}
+
+// diagnoser carries the state of a single diagnostics run so that
+// concurrent runs never share a logger or http client.
+type diagnoser struct {
+	ctx        context.Context
+	opts       Options
+	log        *helpers.Logger
+	dialer     contextDialer
+	resolver   *net.Resolver
+	timeout    time.Duration
+	tlsConfig  *tls.Config
+	httpClient *http.Client
+	redactor   *helpers.Redactor
+	sdk        *sdkProfile
+
+	dnsTimeout       time.Duration
+	bootstrapTimeout time.Duration
+	probeTimeout     time.Duration
+
+	reportedTLSRedirect         bool
+	reportedCapellaConnectivity bool
+	reportedKVAuthMismatch      bool
+	checkedClientAddress        bool
+
+	reportedCertKeys      map[[32]byte]bool
+	reportedRedirectHosts map[string]bool
+	poolsProbes           map[string]poolsProbeResult
+
+	proxySignals []proxySignal
+	endpoints    []Endpoint
+	latencies    []KVLatency
+	nodeHosts    []string
+
+	bootstrapMethod   string
+	bootstrapFallback string
+}
+
+func newDiagnoser(ctx context.Context, opts Options) (*diagnoser, error) {
+	if opts.ConnectTimeout <= 0 {
+		opts.ConnectTimeout = DefaultConnectTimeout
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.DNSTimeout <= 0 {
+		opts.DNSTimeout = opts.Timeout
+	}
+	if opts.BootstrapTimeout <= 0 {
+		opts.BootstrapTimeout = opts.Timeout
+	}
+	if opts.ProbeTimeout <= 0 {
+		opts.ProbeTimeout = opts.Timeout
+	}
+
+	netDialer := &net.Dialer{
+		Timeout: opts.ConnectTimeout,
+	}
+
+	d := &diagnoser{
+		ctx:      ctx,
+		opts:     opts,
+		log:      helpers.NewLogger(opts.Output),
+		dialer:   netDialer,
+		resolver: net.DefaultResolver,
+		timeout:  opts.Timeout,
+
+		dnsTimeout:       opts.DNSTimeout,
+		bootstrapTimeout: opts.BootstrapTimeout,
+		probeTimeout:     opts.ProbeTimeout,
+
+		reportedCertKeys:      make(map[[32]byte]bool),
+		reportedRedirectHosts: make(map[string]bool),
+		poolsProbes:           make(map[string]poolsProbeResult),
+	}
+
+	if opts.SDK != "" {
+		sdk, err := lookupSDK(opts.SDK)
+		if err != nil {
+			return d, err
+		}
+		d.sdk = sdk
+	}
+
+	if opts.Redact {
+		d.redactor = helpers.NewRedactor()
+		d.log.SetRedactor(d.redactor)
+		if d.opts.ConfigOutput != nil {
+			d.opts.ConfigOutput = d.redactor.Writer(d.opts.ConfigOutput)
+		}
+		if d.opts.TopologyOutput != nil {
+			d.opts.TopologyOutput = d.redactor.Writer(d.opts.TopologyOutput)
+		}
+	}
+
+	if opts.DNSServer != "" {
+		d.resolver = newResolver(opts.DNSServer, &net.Dialer{
+			Timeout: opts.DNSTimeout,
+		})
+		netDialer.Resolver = d.resolver
+	}
+
+	if opts.LocalAddr != "" {
+		localIP := net.ParseIP(opts.LocalAddr)
+		if localIP == nil {
+			return d, fmt.Errorf("invalid local address `%s`, expected an IP address", opts.LocalAddr)
+		}
+
+		netDialer.LocalAddr = &net.TCPAddr{IP: localIP}
+	}
+
+	if opts.SOCKS5 != "" {
+		socksDialer, err := helpers.NewSOCKS5Dialer(opts.SOCKS5, netDialer)
+		if err != nil {
+			return d, err
+		}
+
+		d.dialer = socksDialer
+	}
+
+	d.dialer = boundDialer{
+		contextDialer: d.dialer,
+		ctx:           ctx,
+	}
+
+	d.setTLSConfig(opts.TLSConfig)
+
+	return d, nil
+}
+
+// dialMemd connects and authenticates to the memcached service at host:port
+func (d *diagnoser) dialMemd(host string, port int, bucket string) (*helpers.MemdClient, error) {
+	return helpers.DialTimeout(d.dialer, host, port, bucket, d.opts.Username, d.opts.Password, d.tlsConfig, d.timeout)
+}
+
+// doHTTP performs req using the run's http client and additionally returns
+// the local address the request was sent from.
+func (d *diagnoser) doHTTP(req *http.Request) (*http.Response, string, error) {
+	var localAddr string
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			localAddr = info.Conn.LocalAddr().String()
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(d.ctx, trace))
+
+	resp, err := d.httpClient.Do(req)
+	return resp, localAddr, err
+}
+
+// maxDrainBytes bounds how much of an unread response body is drained so that
+// its connection can be reused, larger bodies are cheaper to just drop
+const maxDrainBytes = 64 * 1024
+
+// closeResponse drains what is left of a response body and closes it, so that
+// a run against a large cluster does not leak connections
+func closeResponse(resp *http.Response) {
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+	resp.Body.Close()
+}
+
+// setTLSConfig updates the TLS configuration used for secured connections
+// and rebuilds the http client to match.  Redirects are never followed, the
+// doctor reports them instead.
+func (d *diagnoser) setTLSConfig(tlsConfig *tls.Config) {
+	// Accept outdated protocol versions so that they can be reported, rather
+	//  than failing the handshake without any explanation.
+	if tlsConfig != nil && tlsConfig.MinVersion == 0 {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.MinVersion = tls.VersionTLS10
+	}
+
+	if tlsConfig != nil && d.opts.TLSServerName != "" && tlsConfig.ServerName == "" {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = d.opts.TLSServerName
+	}
+
+	d.tlsConfig = tlsConfig
+
+	var transport http.RoundTripper = &http.Transport{
+		DialContext:     d.dialer.DialContext,
+		TLSClientConfig: tlsConfig,
+	}
+	if d.opts.TraceHTTP {
+		transport = &tracingTransport{
+			next: transport,
+			log:  d.log,
+		}
+	}
+
+	d.httpClient = &http.Client{
+		Transport: transport,
+		Timeout:   d.timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// setTimeout bounds each request of the following checks by timeout, and
+// rebuilds the http client to match
+func (d *diagnoser) setTimeout(timeout time.Duration) {
+	d.timeout = timeout
+	d.setTLSConfig(d.tlsConfig)
+}
+
+// setPhase moves the run on to the named phase, unless the run was cancelled
+func (d *diagnoser) setPhase(phase string) error {
+	if err := d.ctx.Err(); err != nil {
+		return err
+	}
+
+	d.log.SetPhase(phase)
+	return nil
+}
+
+// Run performs diagnostics as specified by opts and returns the findings.
+// An error is returned alongside the report when diagnostics could not be
+// performed at all, such as for an invalid connection string.  Run may be
+// called concurrently.
+func Run(opts Options) (Report, error) {
+	return RunContext(context.Background(), opts)
+}
+
+// RunContext is like Run, but stops diagnosing when ctx is cancelled.  The
+// report then holds the findings of the phases which ran, and is marked as
+// interrupted.
+func RunContext(ctx context.Context, opts Options) (Report, error) {
+	report := Report{
+		Label:   opts.Label,
+		Started: time.Now(),
+	}
+
+	d, err := newDiagnoser(ctx, opts)
+	if err != nil {
+		d.errorf(findingSetupFailed, "Failed to set up diagnostics: %s", err.Error())
+
+		report.Finished = time.Now()
+		report.Entries = d.log.Entries()
+		return report, err
+	}
+
+	if d.opts.SelfTest {
+		d.log.SetPhase(phaseSelfTest)
+		d.selfTest()
+		d.log.NewLine()
+	}
+
+	d.log.SetPhase(phaseConnStr)
+	d.opts.ConnStr = strings.TrimSpace(d.opts.ConnStr)
+	if d.opts.ConnStr == "" {
+		d.opts.ConnStr = DefaultConnStr
+		d.warnf(findingNoConnStr, "No connection string specified, defaulting to `%s`", d.opts.ConnStr)
+	}
+	d.redactConnStr(d.opts.ConnStr)
+	if d.opts.Bucket != "" {
+		d.redactBucket(d.opts.Bucket)
+	}
+	d.redactServerNames()
+	report.ConnStr = d.opts.ConnStr
+
+	err = d.diagnose()
+
+	if ctx.Err() != nil {
+		report.Interrupted = true
+		d.log.Log("Diagnostics were interrupted, only the phases which ran were diagnosed")
+	} else {
+		d.log.Log("Diagnostics completed")
+	}
+
+	report.Finished = time.Now()
+	report.Phases = d.log.Phases()
+	report.Endpoints = d.endpoints
+	report.Latencies = d.latencies
+	report.Nodes = d.nodeHosts
+	report.Bootstrap = d.bootstrapMethod
+	report.BootstrapFallback = d.bootstrapFallback
+	d.redactReport(&report)
+	report.Entries = d.log.Entries()
+
+	return report, err
+}
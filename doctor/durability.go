@@ -0,0 +1,24 @@
+package doctor
+
+// checkDurabilityQuorum notes how many node failures durable writes to bucket
+// can tolerate when the cluster has an even number of Data service nodes.
+// Durable writes must reach a majority of the copies of a document, so adding
+// a node to an odd-sized cluster does not always make them more resilient.
+func (d *diagnoser) checkDurabilityQuorum(bucket string, replicas, kvNodes int) {
+	if kvNodes == 0 || kvNodes%2 != 0 {
+		return
+	}
+
+	copies := replicas + 1
+	if copies > kvNodes {
+		// Already reported as replicas which cannot be placed
+		return
+	}
+
+	majority := copies/2 + 1
+	d.log.Detail("The cluster has an even number of Data service nodes (%d) and bucket `%s` keeps %d"+
+		" copies of each document.  Durable writes must reach %d of those copies, so they tolerate"+
+		" the loss of %d node(s) holding a copy before timing out.  If durable writes time out"+
+		" during failover or rebalance, consider the node and replica counts together.",
+		kvNodes, bucket, copies, majority, copies-majority)
+}
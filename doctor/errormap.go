@@ -0,0 +1,31 @@
+package doctor
+
+import (
+	"errors"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// errorMapVersion is the newest error map version the doctor requests
+const errorMapVersion = 2
+
+// reportErrorMap fetches the error map which SDKs use to interpret unknown KV
+// status codes, and warns when the server cannot provide one.
+func (d *diagnoser) reportErrorMap(client *helpers.MemdClient, host string, port int) {
+	errMap, err := client.GetErrorMap(errorMapVersion)
+	if errors.Is(err, helpers.ErrErrorMapUnsupported) {
+		d.warnf(findingNoErrorMap,
+			"Key Value service at `%s:%d` does not support error maps, which means it is running an"+
+				" old server version.  Newer SDKs rely on the error map to handle unfamiliar errors, and"+
+				" may report them unhelpfully or fail to retry them.",
+			host, port)
+		return
+	} else if err != nil {
+		d.warnf(findingKVRequestFailed, "Failed to fetch the error map from `%s:%d` (error: %s)",
+			host, port, err.Error())
+		return
+	}
+
+	d.log.Log("Key Value service at `%s:%d` provided error map version %d (revision %d, %d errors)",
+		host, port, errMap.Version, errMap.Revision, len(errMap.Errors))
+}
@@ -0,0 +1,235 @@
+package doctor
+
+import "github.com/couchbaselabs/sdk-doctor/helpers"
+
+// finding identifies a kind of problem the doctor reports, so that every
+// occurrence of it carries the same code and remediation hint.
+type finding string
+
+// The kinds of problems which carry a code and remediation hint
+const (
+	findingHTTPScheme             finding = "connstr-http-scheme"
+	findingUnknownOption          finding = "connstr-unknown-option"
+	findingDeprecatedOption       finding = "connstr-deprecated-option"
+	findingMismatchedEndpoints    finding = "connstr-mismatched-endpoints"
+	findingSDKUnsupported         finding = "connstr-sdk-unsupported"
+	findingMixedSSL               finding = "connstr-mixed-ssl"
+	findingNonBootstrapPort       finding = "connstr-non-bootstrap-port"
+	findingInconsistentPorts      finding = "connstr-inconsistent-ports"
+	findingSingleHost             finding = "connstr-single-host"
+	findingNoBucket               finding = "connstr-no-bucket"
+	findingConnStrInvalid         finding = "connstr-invalid"
+	findingNoConnStr              finding = "connstr-missing"
+	findingUnusualNetwork         finding = "connstr-unusual-network"
+	findingIneffectiveSSLOption   finding = "connstr-ineffective-ssl-option"
+	findingIneffectiveMgmtPort    finding = "connstr-ineffective-mgmt-port"
+	findingNoTLSCA                finding = "tls-no-ca"
+	findingTLSRedirect            finding = "tls-enforced"
+	findingWeakCertKey            finding = "tls-weak-certificate-key"
+	findingOutdatedTLS            finding = "tls-outdated-version"
+	findingTLSNameMismatch        finding = "tls-name-mismatch"
+	findingSNIWithoutTLS          finding = "tls-sni-without-tls"
+	findingPlainWithoutTLS        finding = "sasl-plain-without-tls"
+	findingKVAuthRejected         finding = "sasl-kv-auth-rejected"
+	findingNoErrorMap             finding = "kv-no-error-map"
+	findingKVBucketAccess         finding = "sasl-kv-bucket-access"
+	findingKVFeatures             finding = "kv-missing-features"
+	findingKVRequestFailed        finding = "kv-request-failed"
+	findingCapellaNoTLS           finding = "capella-no-tls"
+	findingCapellaNoUsername      finding = "capella-no-username"
+	findingCapellaUnreachable     finding = "capella-unreachable"
+	findingSrvTrailingDot         finding = "dns-srv-trailing-dot"
+	findingSrvStaleTarget         finding = "dns-srv-stale-target"
+	findingSrvAndARecords         finding = "dns-srv-and-a-records"
+	findingSrvDistribution        finding = "dns-srv-distribution"
+	findingNoDNSEntry             finding = "dns-no-entry"
+	findingMultipleDNSEntries     finding = "dns-multiple-entries"
+	findingSrvServerMismatch      finding = "dns-srv-server-mismatch"
+	findingDNSLookupFailed        finding = "dns-lookup-failed"
+	findingDifferentCluster       finding = "bootstrap-different-cluster"
+	findingNonCanonicalHostname   finding = "bootstrap-non-canonical-hostname"
+	findingNoCanonicalSeeds       finding = "bootstrap-no-canonical-seeds"
+	findingNetworkUnavailable     finding = "bootstrap-network-unavailable"
+	findingPortProtocolMismatch   finding = "bootstrap-port-protocol-mismatch"
+	findingEndpointsUnreachable   finding = "bootstrap-unreachable"
+	findingCredentialsRejected    finding = "bootstrap-credentials-rejected"
+	findingBucketUnavailable      finding = "bootstrap-bucket-unavailable"
+	findingNonOptimalBootstrap    finding = "bootstrap-non-optimal"
+	findingCCCPFailed             finding = "bootstrap-cccp-failed"
+	findingServiceConflict        finding = "bootstrap-service-conflict"
+	findingReverseProxy           finding = "bootstrap-reverse-proxy"
+	findingConfigRevDivergence    finding = "bootstrap-config-rev-divergence"
+	findingRedirectUnresolvable   finding = "bootstrap-redirect-unresolvable"
+	findingClientNAT              finding = "bootstrap-client-nat"
+	findingMultipleThisNodes      finding = "bootstrap-multiple-this-nodes"
+	findingNotCouchbase           finding = "bootstrap-not-couchbase"
+	findingUnexpectedCluster      finding = "bootstrap-unexpected-cluster"
+	findingBootstrapEndpoint      finding = "bootstrap-endpoint-failed"
+	findingBootstrapFailed        finding = "bootstrap-failed"
+	findingNoClusterUUID          finding = "bootstrap-no-uuid"
+	findingNoKVNodes              finding = "cluster-no-kv-nodes"
+	findingNodeNoServices         finding = "cluster-node-no-services"
+	findingNoKVSSL                finding = "cluster-no-kv-ssl"
+	findingNodeUnhealthy          finding = "cluster-node-unhealthy"
+	findingCompatVersion          finding = "cluster-compat-version"
+	findingBucketNodesMismatch    finding = "cluster-bucket-nodes-mismatch"
+	findingClusterInfoFailed      finding = "cluster-info-unavailable"
+	findingReplicasUnsatisfiable  finding = "bucket-replicas-unsatisfiable"
+	findingLargeResponseTruncated finding = "bucket-large-response-truncated"
+	findingDurabilityUnsupported  finding = "bucket-durability-unsupported"
+	findingBucketInfoFailed       finding = "bucket-info-unavailable"
+	findingBucketListFailed       finding = "bucket-list-failed"
+	findingBucketOpenFailed       finding = "bucket-open-failed"
+	findingCollectionsUnsupported finding = "collections-unsupported"
+	findingScopeMissing           finding = "collections-scope-missing"
+	findingCollectionMissing      finding = "collections-collection-missing"
+	findingManifestFailed         finding = "collections-manifest-unavailable"
+	findingServiceUnreachable     finding = "service-unreachable"
+	findingQueryNodesMismatch     finding = "service-query-nodes-mismatch"
+	findingQueryKeyspaces         finding = "service-query-keyspaces"
+	findingCouchAPIBase           finding = "service-couch-api-base"
+	findingMgmtNotCouchbase       finding = "service-mgmt-not-couchbase"
+	findingServiceNotAdvertised   finding = "service-not-advertised"
+	findingQueryFailed            finding = "service-query-failed"
+	findingSlowKV                 finding = "performance-slow-kv"
+	findingPerformanceUnavailable finding = "performance-kv-unavailable"
+	findingIdleTimeout            finding = "idle-connection-dropped"
+	findingIdleTestUnavailable    finding = "idle-test-unavailable"
+	findingProxyEnvironment       finding = "selftest-proxy-environment"
+	findingClockSkew              finding = "selftest-clock-skew"
+	findingLowOpenFileLimit       finding = "selftest-low-open-file-limit"
+	findingLocalhostUnresolvable  finding = "selftest-localhost-unresolvable"
+	findingPublicDNS              finding = "selftest-public-dns"
+	findingOutboundConnectivity   finding = "selftest-outbound-connectivity"
+	findingSetupFailed            finding = "doctor-setup-failed"
+	findingOutputFailed           finding = "doctor-output-failed"
+	findingRawConfigMalformed     finding = "doctor-raw-config-malformed"
+)
+
+// findingDefinition describes a kind of problem the doctor reports
+type findingDefinition struct {
+	// Code is a short stable identifier, printed alongside every occurrence of
+	// the problem so that it can be searched for and keyed off by tooling
+	Code string
+
+	// Remediation is a short hint on how to fix the problem
+	Remediation string
+}
+
+// findingDefinitions is the registry of every kind of problem the doctor
+// reports.  Codes must never be reused or renumbered once released.
+var findingDefinitions = map[finding]findingDefinition{
+	findingHTTPScheme:             {"CONN001", "switch the connection string to the couchbase:// scheme"},
+	findingUnknownOption:          {"CONN002", "fix the spelling of the option or remove it"},
+	findingDeprecatedOption:       {"CONN003", "replace the option with its current equivalent"},
+	findingMismatchedEndpoints:    {"CONN004", "remove the explicit ports from the connection string"},
+	findingSDKUnsupported:         {"CONN005", "upgrade the SDK, or stop relying on the feature"},
+	findingMixedSSL:               {"CONN006", "make the scheme and ports of the connection string agree on TLS"},
+	findingNonBootstrapPort:       {"CONN007", "remove the port from the connection string, or use the Key Value port"},
+	findingInconsistentPorts:      {"CONN008", "fix or remove the explicit ports in the connection string"},
+	findingSingleHost:             {"CONN009", "add more seed nodes to the connection string"},
+	findingNoBucket:               {"CONN010", "add the bucket to the connection string, or pass --bucket"},
+	findingConnStrInvalid:         {"CONN011", "fix the syntax of the connection string"},
+	findingNoConnStr:              {"CONN012", "pass the connection string the application uses"},
+	findingUnusualNetwork:         {"CONN013", "use `auto`, `default` or `external`, unless the nodes define alternate addresses of this name"},
+	findingIneffectiveSSLOption:   {"CONN014", "remove the ssl option, or use the couchbases:// scheme with ssl=no_verify"},
+	findingIneffectiveMgmtPort:    {"CONN015", "remove --mgmt-port, or the ports of the connection string's hosts"},
+	findingNoTLSCA:                {"TLS001", "pass the cluster's CA certificate with --tls-ca"},
+	findingTLSRedirect:            {"TLS002", "switch the connection string to the couchbases:// scheme"},
+	findingWeakCertKey:            {"TLS003", "reissue the cluster's certificates with at least RSA-2048 or ECDSA-P256 keys"},
+	findingOutdatedTLS:            {"TLS004", "raise the cluster's minimum TLS version to TLS 1.2"},
+	findingTLSNameMismatch:        {"TLS005", "reissue the cluster's certificates with the hostnames clients connect to"},
+	findingSNIWithoutTLS:          {"TLS006", "remove --tls-sni, or switch the connection string to the couchbases:// scheme"},
+	findingPlainWithoutTLS:        {"KV001", "switch the connection string to the couchbases:// scheme"},
+	findingKVAuthRejected:         {"KV002", "check that the user exists in the same realm for every service, and the password"},
+	findingNoErrorMap:             {"KV003", "upgrade the cluster to a supported server version"},
+	findingKVBucketAccess:         {"KV004", "grant the user a data role (such as Data Reader) on the bucket"},
+	findingKVFeatures:             {"KV005", "upgrade the cluster, or disable the features in the SDK"},
+	findingKVRequestFailed:        {"KV006", "check the Key Value service's logs on the node"},
+	findingCapellaNoTLS:           {"CAP001", "use the couchbases:// connection string shown in the Capella UI"},
+	findingCapellaNoUsername:      {"CAP002", "create database credentials in Capella and pass them with --username and --password"},
+	findingCapellaUnreachable:     {"CAP003", "add this machine's public IP address to the database's allowed IP list"},
+	findingSrvTrailingDot:         {"DNS001", "add a trailing dot to the SRV record targets"},
+	findingSrvStaleTarget:         {"DNS002", "remove the SRV record entries for hosts which no longer exist"},
+	findingSrvAndARecords:         {"DNS003", "remove the A records from the SRV record name"},
+	findingSrvDistribution:        {"DNS004", "give every SRV record target the same priority and a non-zero weight"},
+	findingNoDNSEntry:             {"DNS005", "check the hostname, or add a DNS entry for it"},
+	findingMultipleDNSEntries:     {"DNS006", "give each node its own hostname resolving to a single address"},
+	findingSrvServerMismatch:      {"DNS007", "publish the SRV records on the DNS server the application uses"},
+	findingDNSLookupFailed:        {"DNS008", "check the hostname, and the DNS resolver of this machine"},
+	findingDifferentCluster:       {"BOOT001", "remove the hosts of other clusters from the connection string"},
+	findingNonCanonicalHostname:   {"BOOT002", "use the node hostnames shown in the cluster's configuration"},
+	findingNoCanonicalSeeds:       {"BOOT003", "use the node hostnames shown in the cluster's configuration"},
+	findingNetworkUnavailable:     {"BOOT004", "configure alternate addresses on every node, or remove the network option"},
+	findingPortProtocolMismatch:   {"BOOT005", "swap the Key Value and Management ports in the connection string"},
+	findingEndpointsUnreachable:   {"BOOT006", "open ports 8091 and 11210 (18091 and 11207 for TLS) to the cluster nodes"},
+	findingCredentialsRejected:    {"BOOT007", "check the username and password"},
+	findingBucketUnavailable:      {"BOOT008", "check the bucket name, and that the user has access to the bucket"},
+	findingNonOptimalBootstrap:    {"BOOT009", "open port 11210 (11207 for TLS) to the cluster nodes"},
+	findingCCCPFailed:             {"BOOT010", "open port 11210 (11207 for TLS) to the cluster nodes, and check the bucket access of the user"},
+	findingServiceConflict:        {"BOOT011", "check the alternate addresses and service ports configured on the nodes"},
+	findingReverseProxy:           {"BOOT012", "give the application servers direct access to every cluster node"},
+	findingConfigRevDivergence:    {"BOOT013", "wait for the rebalance or failover to finish, then run the doctor again"},
+	findingRedirectUnresolvable:   {"BOOT014", "make the redirect target resolvable from the application servers, or redirect to a resolvable name"},
+	findingClientNAT:              {"BOOT015", "add this network's public address, rather than the local address, to the cluster's IP allowlist"},
+	findingMultipleThisNodes:      {"BOOT016", "check for proxies rewriting the configuration, or contact Couchbase support"},
+	findingNotCouchbase:           {"BOOT017", "check the connection string's hosts and ports, and any proxies or firewalls on the way"},
+	findingUnexpectedCluster:      {"BOOT018", "point the connection string at the intended cluster, or fix the expected UUID"},
+	findingBootstrapEndpoint:      {"BOOT019", "check that the endpoint is reachable, and accepts the credentials and bucket"},
+	findingBootstrapFailed:        {"BOOT020", "fix the reasons listed for each endpoint"},
+	findingNoClusterUUID:          {"BOOT021", "upgrade the cluster, or stop passing --expect-uuid"},
+	findingNoKVNodes:              {"CLUS001", "add a node running the Data service to the cluster"},
+	findingNodeNoServices:         {"CLUS002", "finish or stop the rebalance, or remove the failed over node"},
+	findingNoKVSSL:                {"CLUS003", "check that the encrypted Key Value port is enabled and not hidden by alternate addresses"},
+	findingNodeUnhealthy:          {"CLUS004", "wait for the node to recover, or fail it over"},
+	findingCompatVersion:          {"CLUS005", "finish upgrading every node of the cluster"},
+	findingBucketNodesMismatch:    {"CLUS006", "finish the rebalance, or rebalance the cluster to distribute the bucket to every node"},
+	findingClusterInfoFailed:      {"CLUS007", "grant the user a role which may read the cluster's configuration"},
+	findingReplicasUnsatisfiable:  {"BKT001", "add more nodes running the Data service, or lower the bucket's replica count"},
+	findingLargeResponseTruncated: {"BKT002", "check the proxies, firewalls and MTU settings between this machine and the cluster"},
+	findingDurabilityUnsupported:  {"BKT003", "use a durability level the bucket supports, or upgrade the cluster"},
+	findingBucketInfoFailed:       {"BKT004", "grant the user a role which may read the bucket's settings"},
+	findingBucketListFailed:       {"BKT005", "use credentials with a role which may list every bucket, such as Cluster Admin"},
+	findingBucketOpenFailed:       {"BKT006", "grant the user access to the bucket, or check the bucket's state"},
+	findingCollectionsUnsupported: {"COLL001", "use the default collection, or upgrade the cluster to 7.0 or later"},
+	findingScopeMissing:           {"COLL002", "create the scope, or fix its name"},
+	findingCollectionMissing:      {"COLL003", "create the collection, or fix its name"},
+	findingManifestFailed:         {"COLL004", "grant the user access to the bucket"},
+	findingServiceUnreachable:     {"SVC001", "open the service's port to this machine"},
+	findingQueryNodesMismatch:     {"SVC002", "restart the query service on the affected node"},
+	findingQueryKeyspaces:         {"SVC003", "grant the user the Query System Catalog role, or restart the query service on the affected node"},
+	findingCouchAPIBase:           {"SVC004", "check the hostname the node was added to the cluster with"},
+	findingMgmtNotCouchbase:       {"SVC005", "check which process listens on the management port, and any proxies on the way"},
+	findingServiceNotAdvertised:   {"SVC006", "check the node's services and the alternate ports it is configured with"},
+	findingQueryFailed:            {"SVC007", "check the query service's logs on the node, and the user's query roles"},
+	findingSlowKV:                 {"PERF001", "check the network path between this machine and the cluster"},
+	findingPerformanceUnavailable: {"PERF002", "open the Key Value port to this machine"},
+	findingIdleTimeout:            {"IDLE001", "lower the SDK's TCP keepalive interval below the idle timeout"},
+	findingIdleTestUnavailable:    {"IDLE002", "open the Key Value port to this machine"},
+	findingProxyEnvironment:       {"SELF001", "add the cluster hosts to NO_PROXY"},
+	findingClockSkew:              {"SELF002", "synchronize the local clock using NTP"},
+	findingLowOpenFileLimit:       {"SELF003", "raise the nofile limit (ulimit -n) of the application's user or service"},
+	findingLocalhostUnresolvable:  {"SELF004", "fix the localhost entry of the hosts file"},
+	findingPublicDNS:              {"SELF005", "check the DNS resolver of this machine, unless it is air-gapped"},
+	findingOutboundConnectivity:   {"SELF006", "check the firewall rules for outbound connections, unless the machine is air-gapped"},
+	findingSetupFailed:            {"DOC001", "fix the options passed to the doctor"},
+	findingOutputFailed:           {"DOC002", "check that the output file can be written"},
+	findingRawConfigMalformed:     {"DOC003", "report the malformed configuration to Couchbase support"},
+}
+
+// warnf logs a warning reporting a particular kind of problem
+func (d *diagnoser) warnf(kind finding, format string, args ...interface{}) {
+	d.log.Finding(helpers.LogWarn, string(kind), findingDefinitions[kind].Code, d.suggestion(kind), format, args...)
+}
+
+// errorf logs an error reporting a particular kind of problem
+func (d *diagnoser) errorf(kind finding, format string, args ...interface{}) {
+	d.log.Finding(helpers.LogError, string(kind), findingDefinitions[kind].Code, d.suggestion(kind), format, args...)
+}
+
+func (d *diagnoser) suggestion(kind finding) string {
+	if d.opts.NoSuggestions {
+		return ""
+	}
+	return findingDefinitions[kind].Remediation
+}
@@ -0,0 +1,186 @@
+package doctor
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// DefaultFormat is the name of the format used for human consumption
+const DefaultFormat = "text"
+
+// Formatter writes a report to w in a particular format
+type Formatter func(w io.Writer, report Report) error
+
+// formattersLock guards formatters, as formats may be registered at any time
+var formattersLock sync.RWMutex
+
+var formatters = map[string]Formatter{
+	"text":  writeTextReport,
+	"json":  writeJSONReport,
+	"junit": writeJUnitReport,
+}
+
+// RegisterFormat makes a formatter available under the given name
+func RegisterFormat(name string, formatter Formatter) {
+	formattersLock.Lock()
+	defer formattersLock.Unlock()
+
+	formatters[name] = formatter
+}
+
+// Formats returns the names of all available formats
+func Formats() []string {
+	formattersLock.RLock()
+	defer formattersLock.RUnlock()
+
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteFormatted writes the report to w in the named format
+func (report Report) WriteFormatted(w io.Writer, format string) error {
+	formattersLock.RLock()
+	formatter, found := formatters[format]
+	formattersLock.RUnlock()
+
+	if !found {
+		return fmt.Errorf("unknown format `%s` (expected one of: %s)",
+			format, strings.Join(Formats(), ", "))
+	}
+
+	return formatter(w, report)
+}
+
+func writeTextReport(w io.Writer, report Report) error {
+	report.PrintSummary(w)
+	return nil
+}
+
+func writeJSONReport(w io.Writer, report Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitResult struct {
+	Message string `xml:"message,attr,omitempty"`
+	Body    string `xml:",cdata"`
+}
+
+type junitOutput struct {
+	Body string `xml:",cdata"`
+}
+
+type junitTestCase struct {
+	ClassName string       `xml:"classname,attr"`
+	Name      string       `xml:"name,attr"`
+	Time      float64      `xml:"time,attr"`
+	Failure   *junitResult `xml:"failure,omitempty"`
+	Skipped   *junitResult `xml:"skipped,omitempty"`
+	SystemOut *junitOutput `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName    xml.Name        `xml:"testsuite"`
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       float64         `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+// writeJUnitReport emits every phase as a testcase, which fails when the
+// phase emitted errors and is skipped when the phase was never reached.
+func writeJUnitReport(w io.Writer, report Report) error {
+	suite := junitTestSuite{
+		Name:      "sdk-doctor",
+		Time:      report.Finished.Sub(report.Started).Seconds(),
+		Timestamp: report.Started.Format("2006-01-02T15:04:05"),
+		Properties: []junitProperty{
+			{Name: "connStr", Value: report.ConnStr},
+		},
+	}
+	if report.Label != "" {
+		suite.Name += " (" + report.Label + ")"
+		suite.Properties = append(suite.Properties, junitProperty{Name: "label", Value: report.Label})
+	}
+
+	for _, phase := range allPhases {
+		testCase := junitTestCase{
+			ClassName: "sdk-doctor",
+			Name:      phase,
+		}
+
+		if !report.PhaseRan(phase) {
+			testCase.Skipped = &junitResult{
+				Message: "phase was not reached",
+			}
+			suite.Skipped++
+		} else {
+			entries := report.PhaseEntries(phase)
+
+			var output, errors []string
+			for _, entry := range entries {
+				output = append(output, fmt.Sprintf("%s %s", entry.Level, entry.CodedMessage()))
+				if entry.Level == helpers.LogError {
+					errors = append(errors, entry.CodedMessage())
+				}
+			}
+
+			if len(entries) > 0 {
+				testCase.Time = entries[len(entries)-1].Time.Sub(entries[0].Time).Seconds()
+			}
+
+			if len(errors) > 0 {
+				testCase.Failure = &junitResult{
+					Message: errors[0],
+					Body:    strings.Join(errors, "\n"),
+				}
+				suite.Failures++
+			}
+
+			if len(output) > 0 {
+				testCase.SystemOut = &junitOutput{
+					Body: strings.Join(output, "\n"),
+				}
+			}
+		}
+
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err = encoder.Encode(suite)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
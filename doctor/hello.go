@@ -0,0 +1,75 @@
+package doctor
+
+import (
+	"strings"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+	"github.com/couchbaselabs/sdk-doctor/memd"
+)
+
+// helloClientName identifies the doctor's connections in the server's logs
+const helloClientName = "sdk-doctor"
+
+// helloFeatures lists the features the doctor asks for during HELLO, in the
+// order they are reported.  Those marked expected are relied on by current
+// SDKs, and their absence is reported as a warning.
+var helloFeatures = []struct {
+	Feature  memd.HelloFeature
+	Name     string
+	Expected bool
+}{
+	{memd.FeatureDatatype, "datatype", false},
+	{memd.FeatureJSON, "JSON datatype", true},
+	{memd.FeatureSnappy, "snappy compression", true},
+	{memd.FeatureXattr, "extended attributes", false},
+	{memd.FeatureXerror, "extended errors", false},
+	{memd.FeatureSelectBucket, "select bucket", false},
+	{memd.FeatureAltRequests, "flexible framing (frame info)", true},
+	{memd.FeatureSyncReplication, "synchronous replication", false},
+	{memd.FeatureCollections, "collections", false},
+}
+
+// reportHelloFeatures negotiates features with a KV node the way an SDK does,
+// and warns about the features applications commonly rely on which the server
+// does not support.
+func (d *diagnoser) reportHelloFeatures(client *helpers.MemdClient, host string, port int) {
+	requested := make([]memd.HelloFeature, 0, len(helloFeatures))
+	for _, feature := range helloFeatures {
+		requested = append(requested, feature.Feature)
+	}
+
+	enabled, err := client.Hello(helloClientName, requested)
+	if err != nil {
+		d.warnf(findingKVRequestFailed, "Failed to negotiate features with `%s:%d` (error: %s)",
+			host, port, err.Error())
+		return
+	}
+
+	enabledSet := make(map[memd.HelloFeature]bool)
+	for _, feature := range enabled {
+		enabledSet[feature] = true
+	}
+
+	var supported, missing []string
+	for _, feature := range helloFeatures {
+		if enabledSet[feature.Feature] {
+			supported = append(supported, feature.Name)
+		} else if feature.Expected {
+			missing = append(missing, feature.Name)
+		}
+	}
+
+	if len(supported) == 0 {
+		supported = append(supported, "none")
+	}
+	d.log.Log("Key Value service at `%s:%d` negotiated features: %s",
+		host, port, strings.Join(supported, ", "))
+
+	if len(missing) > 0 {
+		d.warnf(findingKVFeatures,
+			"Key Value service at `%s:%d` does not support %s.  SDKs silently fall back when a"+
+				" feature is missing, so applications enabling compression or relying on JSON flags"+
+				" and per-request options may behave differently than against newer servers.",
+			host, port, strings.Join(missing, ", "))
+	}
+}
@@ -0,0 +1,89 @@
+package doctor
+
+import "time"
+
+const (
+	// idleTestFirstInterval is how long the connection sits idle before the first NOOP,
+	//  each following interval is twice as long as the previous one.
+	idleTestFirstInterval = 15 * time.Second
+
+	// idleTestPingTimeout bounds how long we wait for a NOOP reply after an idle period
+	idleTestPingTimeout = 5 * time.Second
+)
+
+// testIdleConnection holds a KV connection open for up to the configured duration,
+// leaving it idle for increasingly long periods between NOOPs, to detect intermediaries
+// (usually stateful firewalls) which silently drop idle connections.
+func (d *diagnoser) testIdleConnection(nodesList []clusterNode, bucket string) {
+	kvSvcKey := "kv"
+	if d.tlsConfig != nil {
+		kvSvcKey = "kvSSL"
+	}
+
+	var target *clusterNode
+	for _, node := range nodesList {
+		if node.Services[kvSvcKey] != 0 {
+			target = &node
+			break
+		}
+	}
+
+	if target == nil {
+		d.warnf(findingIdleTestUnavailable,
+			"Could not perform the idle connection test as no node advertises the Key Value service")
+		return
+	}
+
+	kvPort := target.Services[kvSvcKey]
+
+	client, err := d.dialMemd(target.Hostname, kvPort, bucket)
+	if err != nil {
+		d.warnf(findingIdleTestUnavailable,
+			"Failed to perform the idle connection test on `%s:%d` (error: %s)",
+			target.Hostname, kvPort, err.Error())
+		return
+	}
+	defer client.Close()
+
+	d.log.Log("Holding an idle KV connection to `%s:%d` open for up to %s",
+		target.Hostname, kvPort, d.opts.IdleTest)
+
+	startTime := time.Now()
+	var survivedIdle time.Duration
+
+	for interval := idleTestFirstInterval; ; interval *= 2 {
+		remaining := d.opts.IdleTest - time.Since(startTime)
+		if remaining < time.Second {
+			break
+		}
+		if interval > remaining {
+			interval = remaining.Round(time.Second)
+		}
+
+		d.log.Log("Leaving the connection idle for %s", interval)
+		select {
+		case <-time.After(interval):
+		case <-d.ctx.Done():
+			return
+		}
+
+		err := client.PingWithTimeout(idleTestPingTimeout)
+		if err != nil {
+			d.errorf(findingIdleTimeout,
+				"KV connection to `%s:%d` was dropped after being idle for %s, having previously"+
+					" survived being idle for %s (error: %s).  This usually means a firewall or other"+
+					" intermediary is silently dropping idle connections, SDK connections will be"+
+					" disconnected unless their keepalive interval is shorter than this timeout.",
+				target.Hostname, kvPort, interval, survivedIdle, err.Error())
+			d.log.Detail("Observed idle connection timeout: between %s and %s", survivedIdle, interval)
+			return
+		}
+
+		if interval > survivedIdle {
+			survivedIdle = interval
+		}
+	}
+
+	d.log.Log("KV connection to `%s:%d` survived being idle for up to %s",
+		target.Hostname, kvPort, survivedIdle)
+}
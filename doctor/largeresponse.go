@@ -0,0 +1,35 @@
+package doctor
+
+import (
+	"errors"
+	"net"
+)
+
+// checkLargeResponse interprets how fetching the full bucket config went.  It is
+// one of the largest responses the management service sends, so it failing to
+// download while small requests succeed points at a proxy or firewall which
+// truncates large responses, or a path MTU problem.
+func (d *diagnoser) checkLargeResponse(host string, port int, configSize int, err error, poolsProbe *poolsProbeResult) {
+	if err == nil {
+		d.log.Log("Downloaded the %d byte bucket config from `%s:%d` intact", configSize, host, port)
+		return
+	}
+
+	var netErr net.Error
+	truncated := errors.Is(err, errConfigTruncated)
+	stalled := errors.As(err, &netErr) && netErr.Timeout()
+	if !truncated && !stalled {
+		return
+	}
+
+	if poolsProbe == nil || !poolsProbe.Reachable() {
+		return
+	}
+
+	d.warnf(findingLargeResponseTruncated,
+		"Small requests to the management service at `%s:%d` succeed, but downloading the full bucket"+
+			" config was cut short (error: %s).  A proxy or firewall may be truncating large responses,"+
+			" or the path MTU may be misconfigured.  Bootstrapping will then fail once the cluster's"+
+			" configs grow, such as when nodes or buckets are added.",
+		host, port, err.Error())
+}
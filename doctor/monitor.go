@@ -0,0 +1,261 @@
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+	"github.com/fatih/color"
+)
+
+// latencyRegressionFactor is how many times slower than in the previous run the
+// p95 KV latency of a node must be to be reported as a regression
+const latencyRegressionFactor = 2
+
+// minLatencyRegression is the smallest increase of the p95 KV latency of a node
+// which is reported as a regression, so that jitter on fast networks is not
+const minLatencyRegression = time.Millisecond
+
+// problem is a warning or error as it is recognized across runs
+type problem struct {
+	// key identifies the problem, and text describes it for listings
+	key  string
+	text string
+}
+
+// findingSubject returns the first value quoted in a message, which names the
+// host, endpoint or bucket a finding concerns
+func findingSubject(message string) string {
+	start := strings.Index(message, "`")
+	if start < 0 {
+		return ""
+	}
+	end := strings.Index(message[start+1:], "`")
+	if end < 0 {
+		return ""
+	}
+	return message[start+1 : start+1+end]
+}
+
+// problemKey identifies a warning or error across runs.  Findings are keyed on
+// their code and what they concern rather than their message, which contains
+// values such as latencies that change from run to run.
+func problemKey(entry helpers.LogEntry) string {
+	if entry.Code == "" {
+		return entry.Level.String() + " " + entry.Message
+	}
+	return entry.Level.String() + " " + entry.Code + " " + findingSubject(entry.Message)
+}
+
+// problemText describes a warning or error for listings
+func problemText(entry helpers.LogEntry) string {
+	tag := "[WARN]"
+	if entry.Level == helpers.LogError {
+		tag = "[ERRO]"
+	}
+	return tag + " " + entry.CodedMessage()
+}
+
+// problems returns the warnings and errors of report, in order
+func (report Report) problems() []problem {
+	var out []problem
+	seen := make(map[string]bool)
+	for _, entry := range report.Entries {
+		if entry.Level != helpers.LogWarn && entry.Level != helpers.LogError {
+			continue
+		}
+
+		key := problemKey(entry)
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, problem{key, problemText(entry)})
+		}
+	}
+	return out
+}
+
+// ReportDelta describes how a run differs from the run before it
+type ReportDelta struct {
+	// Appeared lists the warnings and errors which the previous run did not report
+	Appeared []string
+
+	// Resolved lists the warnings and errors which are no longer reported
+	Resolved []string
+
+	// Regressions describes the nodes whose KV latency got significantly worse
+	Regressions []string
+}
+
+// Empty returns whether nothing changed between the runs
+func (delta ReportDelta) Empty() bool {
+	return len(delta.Appeared) == 0 && len(delta.Resolved) == 0 && len(delta.Regressions) == 0
+}
+
+// Delta compares report to the previous run against the same cluster
+func (report Report) Delta(previous Report) ReportDelta {
+	var delta ReportDelta
+
+	current := report.problems()
+	before := previous.problems()
+
+	currentSet := make(map[string]bool)
+	for _, problem := range current {
+		currentSet[problem.key] = true
+	}
+	beforeSet := make(map[string]bool)
+	for _, problem := range before {
+		beforeSet[problem.key] = true
+	}
+
+	for _, problem := range current {
+		if !beforeSet[problem.key] {
+			delta.Appeared = append(delta.Appeared, problem.text)
+		}
+	}
+	for _, problem := range before {
+		if !currentSet[problem.key] {
+			delta.Resolved = append(delta.Resolved, problem.text)
+		}
+	}
+
+	previousLatency := make(map[string]KVLatency)
+	for _, latency := range previous.Latencies {
+		previousLatency[helpers.JoinHostPort(latency.Host, latency.Port)] = latency
+	}
+	for _, latency := range report.Latencies {
+		address := helpers.JoinHostPort(latency.Host, latency.Port)
+		before, ok := previousLatency[address]
+		if !ok {
+			continue
+		}
+
+		if latency.P95 >= before.P95*latencyRegressionFactor && latency.P95-before.P95 >= minLatencyRegression {
+			delta.Regressions = append(delta.Regressions, fmt.Sprintf("KV p95 latency to `%s` rose from %s to %s",
+				address, before.P95.Round(time.Microsecond), latency.P95.Round(time.Microsecond)))
+		}
+	}
+
+	return delta
+}
+
+// PrintDelta prints what changed in a run, prefixed by when the run started
+func (delta ReportDelta) PrintDelta(w io.Writer, started time.Time) {
+	stamp := started.Format("15:04:05")
+	if delta.Empty() {
+		fmt.Fprintf(w, "%s No changes\n", stamp)
+		return
+	}
+
+	for _, key := range delta.Appeared {
+		fmt.Fprintf(w, "%s %s %s\n", stamp, color.RedString("+"), key)
+	}
+	for _, key := range delta.Resolved {
+		fmt.Fprintf(w, "%s %s %s\n", stamp, color.GreenString("-"), key)
+	}
+	for _, line := range delta.Regressions {
+		fmt.Fprintf(w, "%s %s %s\n", stamp, color.YellowString("~"), line)
+	}
+}
+
+// History aggregates the reports of repeated runs against the same cluster
+type History struct {
+	runs        int
+	interrupted int
+	problems    []string
+	problemRuns map[string]int
+	problemText map[string]string
+	latencies   map[string][]time.Duration
+	worst       helpers.LogLevel
+}
+
+// Add records the findings of a run
+func (history *History) Add(report Report) {
+	if history.problemRuns == nil {
+		history.problemRuns = make(map[string]int)
+		history.problemText = make(map[string]string)
+		history.latencies = make(map[string][]time.Duration)
+	}
+
+	history.runs++
+	if report.Interrupted {
+		history.interrupted++
+	}
+
+	for _, problem := range report.problems() {
+		if history.problemRuns[problem.key] == 0 {
+			history.problems = append(history.problems, problem.key)
+		}
+		history.problemRuns[problem.key]++
+		history.problemText[problem.key] = problem.text
+	}
+
+	if len(report.Errors()) > 0 {
+		history.worst = helpers.LogError
+	} else if len(report.Warnings()) > 0 && history.worst != helpers.LogError {
+		history.worst = helpers.LogWarn
+	}
+
+	for _, latency := range report.Latencies {
+		address := helpers.JoinHostPort(latency.Host, latency.Port)
+		history.latencies[address] = append(history.latencies[address], latency.P95)
+	}
+}
+
+// Runs returns how many runs were recorded
+func (history *History) Runs() int {
+	return history.runs
+}
+
+// HasErrors returns whether any recorded run reported errors
+func (history *History) HasErrors() bool {
+	return history.worst == helpers.LogError
+}
+
+// HasWarnings returns whether any recorded run reported warnings
+func (history *History) HasWarnings() bool {
+	return history.worst == helpers.LogWarn
+}
+
+// PrintSummary prints how often each problem was seen, and the range of the
+// KV latency of each node, across every recorded run
+func (history *History) PrintSummary(w io.Writer) {
+	fmt.Fprintf(w, "Aggregate of %d runs:\n", history.runs)
+	if history.interrupted > 0 {
+		fmt.Fprintf(w, "(the last run was interrupted, its results are incomplete)\n")
+	}
+
+	for _, key := range history.problems {
+		fmt.Fprintf(w, "%d/%d runs: %s\n", history.problemRuns[key], history.runs, history.problemText[key])
+	}
+
+	addresses := make([]string, 0, len(history.latencies))
+	for address := range history.latencies {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	for _, address := range addresses {
+		samples := history.latencies[address]
+		best, worst := samples[0], samples[0]
+		for _, sample := range samples {
+			if sample < best {
+				best = sample
+			}
+			if sample > worst {
+				worst = sample
+			}
+		}
+		fmt.Fprintf(w, "KV p95 latency to `%s`: %s best, %s worst over %d runs\n",
+			address, best.Round(time.Microsecond), worst.Round(time.Microsecond), len(samples))
+	}
+
+	fmt.Fprintf(w, "\n")
+	if len(history.problems) == 0 {
+		fmt.Fprintf(w, "No issues were found in any run.\n")
+	} else {
+		fmt.Fprintf(w, "Found issues in at least one run, see listing above.\n")
+	}
+}
@@ -0,0 +1,73 @@
+package doctor
+
+import "net"
+
+// privateNetworks are the address ranges which are not routed on the public
+// internet, so traffic from them to a public address must pass through NAT
+var privateNetworks = func() []*net.IPNet {
+	var out []*net.IPNet
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"} {
+		_, network, _ := net.ParseCIDR(cidr)
+		out = append(out, network)
+	}
+	return out
+}()
+
+func isPrivateIP(ip net.IP) bool {
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPublicIP returns whether ip is routed on the public internet
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !isPrivateIP(ip)
+}
+
+// addrIP returns the IP address of a TCP address, or nil if it has none
+func addrIP(addr net.Addr) net.IP {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP
+	}
+	return nil
+}
+
+// checkClientAddress reports the local address a connection to the cluster
+// was made from next to the address it reached, and warns when a private
+// local address reached a public one.  The cluster then sees the translated
+// public address of this network, which is what IP allowlists must list.
+func (d *diagnoser) checkClientAddress(localAddr, remoteAddr net.Addr, host string, port int) {
+	if d.checkedClientAddress {
+		return
+	}
+
+	// Through a proxy, the connection's peer is the proxy rather than the node
+	if d.opts.SOCKS5 != "" {
+		return
+	}
+
+	localIP, remoteIP := addrIP(localAddr), addrIP(remoteAddr)
+	if localIP == nil || remoteIP == nil {
+		return
+	}
+	d.checkedClientAddress = true
+	d.redactHost(localIP.String())
+	d.redactHost(remoteIP.String())
+
+	d.log.Log("Connections to `%s:%d` reach the address `%s` from the local address `%s`",
+		host, port, remoteIP, localIP)
+
+	if !isPrivateIP(localIP) || !isPublicIP(remoteIP) {
+		return
+	}
+
+	d.warnf(findingClientNAT,
+		"Connections to `%s:%d` leave this machine from the private address `%s`, but reach the"+
+			" public address `%s`, so they pass through NAT.  The cluster sees this network's public"+
+			" address rather than `%s`, so IP allowlists, such as those of Couchbase Capella, must"+
+			" list the public address.",
+		host, port, localIP, remoteIP, localIP)
+}
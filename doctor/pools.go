@@ -0,0 +1,156 @@
+package doctor
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/couchbaselabs/gocbconnstr"
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// poolsProbeResult describes how a management endpoint responded to the
+// unauthenticated `/pools` and authenticated `/pools/default` requests.
+type poolsProbeResult struct {
+	Host string
+	Port int
+
+	// PoolsErr is set when `/pools` could not be fetched at all
+	PoolsErr error
+
+	// DefaultStatus is the status code `/pools/default` responded with
+	DefaultStatus int
+}
+
+// Reachable returns whether the endpoint responded as a management service
+func (result poolsProbeResult) Reachable() bool {
+	return result.PoolsErr == nil
+}
+
+// AuthRejected returns whether the endpoint rejected the credentials
+func (result poolsProbeResult) AuthRejected() bool {
+	return result.DefaultStatus == 401 || result.DefaultStatus == 403
+}
+
+// probePools probes the management endpoint at host and port.  Each endpoint is
+// only probed once per run, as both the credentials and the bootstrap phases
+// rely on the result.
+func (d *diagnoser) probePools(host string, port int) poolsProbeResult {
+	address := helpers.JoinHostPort(host, port)
+	if result, ok := d.poolsProbes[address]; ok {
+		return result
+	}
+
+	result := d.fetchPools(host, port)
+	d.poolsProbes[address] = result
+	return result
+}
+
+func (d *diagnoser) fetchPools(host string, port int) poolsProbeResult {
+	result := poolsProbeResult{
+		Host: host,
+		Port: port,
+	}
+
+	scheme := "http"
+	if d.tlsConfig != nil {
+		scheme = "https"
+	}
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s://%s/pools", scheme, helpers.JoinHostPort(host, port)), nil)
+	resp, _, err := d.doHTTP(req)
+	if err != nil {
+		result.PoolsErr = err
+		return result
+	}
+	closeResponse(resp)
+
+	d.checkProxyHeaders(resp, host, port)
+
+	err = checkRedirect(resp)
+	if err != nil {
+		result.PoolsErr = err
+		return result
+	}
+
+	if resp.StatusCode != 200 {
+		result.PoolsErr = fmt.Errorf("http error (status code: %d)", resp.StatusCode)
+		return result
+	}
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("%s://%s/pools/default", scheme, helpers.JoinHostPort(host, port)), nil)
+	req.SetBasicAuth(d.opts.Username, d.opts.Password)
+	resp, _, err = d.doHTTP(req)
+	if err != nil {
+		result.PoolsErr = err
+		return result
+	}
+	closeResponse(resp)
+
+	result.DefaultStatus = resp.StatusCode
+
+	return result
+}
+
+// credentialsRejected checks the credentials against the first management
+// endpoint which responds, and returns whether they were rejected.  This fails
+// the run fast, rather than after every node hit the same auth failure.
+func (d *diagnoser) credentialsRejected(hosts []gocbconnstr.Address) bool {
+	for _, target := range hosts {
+		result := d.probePools(target.Host, target.Port)
+		if !result.Reachable() {
+			continue
+		}
+
+		if result.AuthRejected() {
+			d.errorf(findingCredentialsRejected,
+				"Management endpoint `%s:%d` rejected the provided credentials for user `%s` (status"+
+					" code: %d).  Check the username and password, further cluster diagnostics are"+
+					" not possible",
+				target.Host, target.Port, d.opts.Username, result.DefaultStatus)
+			return true
+		}
+
+		return false
+	}
+
+	// Unreachable endpoints are diagnosed in detail later on
+	return false
+}
+
+// probeManagementEndpoints probes each HTTP host until one responds, logging
+// what was learned about reachability and credentials along the way.
+func (d *diagnoser) probeManagementEndpoints(hosts []gocbconnstr.Address, useSsl bool) *poolsProbeResult {
+	for _, target := range hosts {
+		d.log.Log("Probing management endpoint `%s:%d`", target.Host, target.Port)
+
+		result := d.probePools(target.Host, target.Port)
+		if !result.Reachable() {
+			d.log.Log("Management endpoint `%s:%d` did not respond to `/pools` (error: %s)",
+				target.Host, target.Port, result.PoolsErr.Error())
+			if redirectErr, ok := result.PoolsErr.(*redirectError); ok && !redirectErr.ToHTTPS {
+				d.addProxySignal(false, "`%s:%d` redirected `/pools` to `%s`",
+					target.Host, target.Port, redirectErr.Location)
+			}
+			d.reportTLSRedirect(result.PoolsErr, useSsl)
+			d.reportUnresolvableRedirect(result.PoolsErr)
+			continue
+		}
+
+		if result.AuthRejected() {
+			d.warnf(findingCredentialsRejected,
+				"Management endpoint `%s:%d` is reachable, but rejected the provided credentials"+
+					" (status code: %d).  Check the username and password being used.",
+				target.Host, target.Port, result.DefaultStatus)
+		} else if result.DefaultStatus != 200 {
+			d.log.Log("Management endpoint `%s:%d` is reachable, but `/pools/default` failed (status code: %d)",
+				target.Host, target.Port, result.DefaultStatus)
+		} else {
+			d.log.Log("Management endpoint `%s:%d` is reachable and accepted the provided credentials",
+				target.Host, target.Port)
+		}
+
+		return &result
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package doctor
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"time"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+	"github.com/couchbaselabs/sdk-doctor/memd"
+)
+
+const (
+	protocolUnknown   = "unknown"
+	protocolMemcached = "memcached"
+	protocolHTTP      = "HTTP"
+)
+
+// detectProtocol works out whether a port speaks memcached or HTTP by sending a
+// memcached NOOP, which an HTTP server rejects with an HTTP error response.
+func (d *diagnoser) detectProtocol(host string, port int) string {
+	conn, err := d.dialer.Dial("tcp", helpers.JoinHostPort(host, port))
+	if err != nil {
+		return protocolUnknown
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(d.timeout))
+
+	if d.tlsConfig != nil {
+		tlsConfig := d.tlsConfig.Clone()
+		tlsConfig.ServerName = helpers.StripIPv6Brackets(host)
+
+		tlsConn := tls.Client(conn, tlsConfig)
+		if tlsConn.Handshake() != nil {
+			return protocolUnknown
+		}
+		conn = tlsConn
+	}
+
+	noop := make([]byte, 24)
+	noop[0] = byte(memd.ReqMagic)
+	noop[1] = byte(memd.CmdNop)
+	_, err = conn.Write(noop)
+	if err != nil {
+		return protocolUnknown
+	}
+
+	reply := make([]byte, 5)
+	_, err = io.ReadFull(conn, reply)
+	if err != nil {
+		return protocolUnknown
+	}
+
+	if reply[0] == byte(memd.ResMagic) {
+		return protocolMemcached
+	}
+	if bytes.Equal(reply, []byte("HTTP/")) {
+		return protocolHTTP
+	}
+	return protocolUnknown
+}
+
+// checkPortProtocol warns when a bootstrap endpoint which was expected to speak
+// one protocol turns out to speak another, which means ports were swapped.
+func (d *diagnoser) checkPortProtocol(host string, port int, method, expected string) {
+	detected := d.detectProtocol(host, port)
+	if detected == protocolUnknown || detected == expected {
+		return
+	}
+
+	d.warnf(findingPortProtocolMismatch,
+		"Bootstrap endpoint `%s:%d` is used for %s bootstrap, but it speaks %s.  This usually means"+
+			" the ports of the Key Value (11210, or 11207 for TLS) and Management (8091, or 18091 for"+
+			" TLS) services were mixed up in your connection string.",
+		host, port, method, detected)
+}
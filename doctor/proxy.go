@@ -0,0 +1,102 @@
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// proxySignal is a symptom of a reverse proxy or load balancer sitting in front
+// of the cluster.  Conclusive signals are enough to report a proxy on their own,
+// the rest only when several of them coincide.
+type proxySignal struct {
+	Description string
+	Conclusive  bool
+}
+
+func (d *diagnoser) addProxySignal(conclusive bool, format string, args ...interface{}) {
+	d.proxySignals = append(d.proxySignals, proxySignal{
+		Description: fmt.Sprintf(format, args...),
+		Conclusive:  conclusive,
+	})
+}
+
+// checkProxyHeaders records the signals carried by the headers of a response
+// which claims to come from the management service at host:port
+func (d *diagnoser) checkProxyHeaders(resp *http.Response, host string, port int) {
+	server := resp.Header.Get("Server")
+	if server != "" && !strings.Contains(strings.ToLower(server), "couchbase") {
+		d.addProxySignal(true, "`%s:%d` identifies itself as `%s`, rather than as Couchbase Server",
+			host, port, server)
+	}
+
+	via := resp.Header.Get("Via")
+	if via != "" {
+		d.addProxySignal(true, "`%s:%d` responded through `%s`", host, port, via)
+	}
+}
+
+// reportNotCouchbase explains a bootstrap endpoint which responded with
+// something other than a Couchbase config, and records it as a proxy signal
+func (d *diagnoser) reportNotCouchbase(err error, host string, port int) {
+	var notCouchbaseErr *notCouchbaseError
+	if !errors.As(err, &notCouchbaseErr) {
+		return
+	}
+
+	server := notCouchbaseErr.Server
+	if server == "" {
+		server = "none"
+	}
+
+	d.addProxySignal(true, "`%s:%d` responded with `%s` content rather than a Couchbase config",
+		host, port, notCouchbaseErr.ContentType)
+	d.errorf(findingNotCouchbase,
+		"`%s:%d` responded, but does not appear to be a Couchbase cluster (content type: `%s`, Server"+
+			" header: `%s`).  This is usually a captive portal, a web application firewall block page or"+
+			" a login page of a proxy intercepting the connection.",
+		host, port, notCouchbaseErr.ContentType, server)
+}
+
+// checkProxyNodes records a signal for hostnames which several nodes share,
+// as happens when a load balancer rewrites every node to its own address
+func (d *diagnoser) checkProxyNodes(nodes []clusterNode) {
+	counts := make(map[string]int)
+	for _, node := range nodes {
+		counts[node.Hostname]++
+	}
+
+	for _, node := range nodes {
+		if counts[node.Hostname] > 1 {
+			d.addProxySignal(false, "%d nodes share the hostname `%s`", counts[node.Hostname], node.Hostname)
+			counts[node.Hostname] = 0
+		}
+	}
+}
+
+// reportProxySignals warns of a reverse proxy or load balancer when the
+// signals which were recorded during bootstrap point to one
+func (d *diagnoser) reportProxySignals() {
+	if len(d.proxySignals) == 0 {
+		return
+	}
+
+	conclusive := false
+	descriptions := make([]string, 0, len(d.proxySignals))
+	for _, signal := range d.proxySignals {
+		conclusive = conclusive || signal.Conclusive
+		descriptions = append(descriptions, "  - "+signal.Description)
+	}
+
+	if !conclusive && len(d.proxySignals) < 2 {
+		d.log.Log("Possible sign of a proxy in front of the cluster: %s", d.proxySignals[0].Description)
+		return
+	}
+
+	d.warnf(findingReverseProxy,
+		"A reverse proxy or load balancer appears to be intercepting Couchbase traffic.  SDKs discover"+
+			" the cluster topology and connect to each node directly, which a proxy in front of the"+
+			" cluster breaks.  The following signals were observed:\n%s",
+		strings.Join(descriptions, "\n"))
+}
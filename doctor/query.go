@@ -0,0 +1,175 @@
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// queryClusterNode is a node as listed by the query service's admin API
+type queryClusterNode struct {
+	Name          string `json:"name"`
+	QueryEndpoint string `json:"queryEndpoint"`
+}
+
+func (d *diagnoser) fetchQueryClusterNodes(scheme, host string, port int) ([]queryClusterNode, error) {
+	uri := fmt.Sprintf("%s://%s/admin/clusters/default/nodes", scheme, helpers.JoinHostPort(host, port))
+	req, _ := http.NewRequest("GET", uri, nil)
+	req.SetBasicAuth(d.opts.Username, d.opts.Password)
+
+	resp, _, err := d.doHTTP(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeResponse(resp)
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("http error (status code: %d)", resp.StatusCode)
+	}
+
+	nodesBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []queryClusterNode
+	err = json.Unmarshal(nodesBytes, &nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+// checkQueryNodes asks every query node which query nodes it sees, and reports
+// those whose view of the cluster differs from the cluster config.  Such a
+// query node produces inconsistent results even though its port is reachable.
+func (d *diagnoser) checkQueryNodes(nodes []clusterNode) {
+	scheme, svcKey := "http", "n1ql"
+	if d.tlsConfig != nil {
+		scheme, svcKey = "https", "n1qlSSL"
+	}
+
+	expected := 0
+	for _, node := range nodes {
+		if node.Services[svcKey] != 0 {
+			expected++
+		}
+	}
+
+	for _, node := range nodes {
+		port := node.Services[svcKey]
+		if port == 0 {
+			continue
+		}
+
+		queryNodes, err := d.fetchQueryClusterNodes(scheme, node.Hostname, port)
+		if err != nil {
+			d.warnf(findingQueryFailed, "Failed to fetch the query nodes known to `%s:%d` (error: %s)",
+				node.Hostname, port, err.Error())
+			continue
+		}
+
+		if len(queryNodes) != expected {
+			d.warnf(findingQueryNodesMismatch,
+				"Query service at `%s:%d` sees %d query nodes, but the cluster config lists %d.  A query"+
+					" service with a stale view of the cluster can make queries fail intermittently.",
+				node.Hostname, port, len(queryNodes), expected)
+		} else {
+			d.log.Log("Query service at `%s:%d` sees all %d query nodes", node.Hostname, port, expected)
+		}
+	}
+}
+
+// keyspacesStatement is run against every query node to check that it can see
+// the cluster's metadata, not merely accept connections
+const keyspacesStatement = "SELECT * FROM system:keyspaces LIMIT 1"
+
+// queryResponse is the part of a query service response the doctor inspects
+type queryResponse struct {
+	Status  string            `json:"status"`
+	Results []json.RawMessage `json:"results"`
+	Errors  []struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	} `json:"errors"`
+}
+
+func (d *diagnoser) runQuery(scheme, host string, port int, statement string) (queryResponse, error) {
+	var out queryResponse
+
+	uri := fmt.Sprintf("%s://%s/query/service", scheme, helpers.JoinHostPort(host, port))
+	form := url.Values{"statement": {statement}}
+	req, _ := http.NewRequest("POST", uri, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(d.opts.Username, d.opts.Password)
+
+	resp, _, err := d.doHTTP(req)
+	if err != nil {
+		return out, err
+	}
+	defer closeResponse(resp)
+
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return out, err
+	}
+
+	// Failed queries still describe their errors in the body
+	if err := json.Unmarshal(respBytes, &out); err != nil {
+		if resp.StatusCode != 200 {
+			return out, fmt.Errorf("http error (status code: %d)", resp.StatusCode)
+		}
+		return out, err
+	}
+
+	return out, nil
+}
+
+// checkQueryKeyspaces queries `system:keyspaces` on every query node, and
+// reports those which cannot see any of the cluster's buckets or collections.
+// Such a query node is up, but fails every query against the cluster's data.
+func (d *diagnoser) checkQueryKeyspaces(nodes []clusterNode) {
+	scheme, svcKey := "http", "n1ql"
+	if d.tlsConfig != nil {
+		scheme, svcKey = "https", "n1qlSSL"
+	}
+
+	for _, node := range nodes {
+		port := node.Services[svcKey]
+		if port == 0 {
+			continue
+		}
+
+		resp, err := d.runQuery(scheme, node.Hostname, port, keyspacesStatement)
+		if err != nil {
+			d.warnf(findingQueryFailed, "Failed to query `system:keyspaces` on `%s:%d` (error: %s)",
+				node.Hostname, port, err.Error())
+			continue
+		}
+
+		if resp.Status != "success" {
+			reason := resp.Status
+			if len(resp.Errors) > 0 {
+				reason = fmt.Sprintf("%d %s", resp.Errors[0].Code, resp.Errors[0].Msg)
+			}
+			d.warnf(findingQueryKeyspaces,
+				"Query service at `%s:%d` failed to query `system:keyspaces` (error: %s).  The service"+
+					" is reachable, but may not be able to run queries against the cluster's data.",
+				node.Hostname, port, reason)
+		} else if len(resp.Results) == 0 {
+			d.warnf(findingQueryKeyspaces,
+				"Query service at `%s:%d` does not see any keyspaces in `system:keyspaces`.  The service"+
+					" is reachable, but has no view of the cluster's buckets and collections, or the user"+
+					" lacks the permission to list them.",
+				node.Hostname, port)
+		} else {
+			d.log.Log("Query service at `%s:%d` sees the cluster's keyspaces", node.Hostname, port)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package doctor
+
+import (
+	"net"
+
+	"github.com/couchbaselabs/gocbconnstr"
+)
+
+// redactHost registers a hostname or IP address to be redacted, if requested
+func (d *diagnoser) redactHost(host string) {
+	if d.redactor != nil {
+		d.redactor.AddHost(host)
+	}
+}
+
+// redactBucket registers a bucket name to be redacted, if requested
+func (d *diagnoser) redactBucket(bucket string) {
+	if d.redactor != nil {
+		d.redactor.AddBucket(bucket)
+	}
+}
+
+// redactHostPort registers the host of a `host:port` address to be redacted
+func (d *diagnoser) redactHostPort(address string) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	d.redactHost(host)
+}
+
+// redactConnStr registers the hosts and bucket of a connection string to be
+// redacted, before anything about it is logged
+func (d *diagnoser) redactConnStr(connStr string) {
+	if d.redactor == nil {
+		return
+	}
+
+	// Parse errors are reported later on, unredacted hosts are then unavoidable
+	connSpec, err := gocbconnstr.Parse(connStr)
+	if err != nil {
+		return
+	}
+
+	for _, address := range connSpec.Addresses {
+		d.redactHost(address.Host)
+	}
+	d.redactBucket(connSpec.Bucket)
+}
+
+// redactServerNames registers the TLS server name and the DNS server given in
+// the options to be redacted, they name the cluster's hosts and network too
+func (d *diagnoser) redactServerNames() {
+	if d.opts.TLSServerName != "" {
+		d.redactHost(d.opts.TLSServerName)
+	}
+	if d.opts.DNSServer != "" {
+		d.redactHostPort(d.opts.DNSServer)
+	}
+}
+
+// redactTerseHosts registers every hostname a terse config advertises, on
+// every network, to be redacted
+func (d *diagnoser) redactTerseHosts(config terseBucketConfig) {
+	for _, node := range config.NodesExt {
+		d.redactHost(node.Hostname)
+		for _, altNames := range node.AlternateNames {
+			d.redactHost(altNames.Hostname)
+		}
+	}
+}
+
+// redactClusterHosts registers every hostname a cluster config advertises to
+// be redacted
+func (d *diagnoser) redactClusterHosts(config clusterConfig) {
+	for _, node := range config.Nodes {
+		d.redactHostPort(node.Hostname)
+	}
+}
+
+// redactReport replaces the hosts of the parts of a report which are not log
+// messages, which have been redacted as they were logged
+func (d *diagnoser) redactReport(report *Report) {
+	if d.redactor == nil {
+		return
+	}
+
+	report.ConnStr = d.redactor.Redact(report.ConnStr)
+	for i := range report.Endpoints {
+		report.Endpoints[i].Host = d.redactor.Redact(report.Endpoints[i].Host)
+	}
+	for i := range report.Nodes {
+		report.Nodes[i] = d.redactor.Redact(report.Nodes[i])
+	}
+	for i := range report.Latencies {
+		report.Latencies[i].Host = d.redactor.Redact(report.Latencies[i].Host)
+	}
+}
@@ -0,0 +1,164 @@
+package doctor
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/couchbaselabs/gocbconnstr"
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// redirectError is returned when a bootstrap request was answered with a redirect,
+// which the doctor never follows so that it can explain where it was sent.
+type redirectError struct {
+	StatusCode int
+	Location   string
+	ToHTTPS    bool
+}
+
+func (err *redirectError) Error() string {
+	return fmt.Sprintf("redirected to `%s` (status code: %d)", err.Location, err.StatusCode)
+}
+
+// checkRedirect returns a redirectError if resp is a redirect
+func checkRedirect(resp *http.Response) error {
+	switch resp.StatusCode {
+	case 301, 302, 303, 307, 308:
+	default:
+		return nil
+	}
+
+	err := &redirectError{
+		StatusCode: resp.StatusCode,
+		Location:   resp.Header.Get("Location"),
+	}
+
+	location, locationErr := resp.Location()
+	if locationErr == nil {
+		err.Location = location.String()
+		err.ToHTTPS = location.Scheme == "https" && resp.Request.URL.Scheme == "http"
+	}
+
+	return err
+}
+
+// reportTLSRedirect explains a redirect from plain http to https, which means the
+// cluster enforces encryption while the connection string does not ask for it.
+func (d *diagnoser) reportTLSRedirect(err error, useSsl bool) bool {
+	redirectErr, ok := err.(*redirectError)
+	if !ok || !redirectErr.ToHTTPS || useSsl || d.reportedTLSRedirect {
+		return false
+	}
+
+	d.reportedTLSRedirect = true
+	d.errorf(findingTLSRedirect,
+		"The cluster redirected a plaintext request to `%s`, which indicates that it enforces"+
+			" encrypted connections.  Switch your connection string to the `couchbases://` scheme"+
+			" (and specify the cluster's certificate authority with --tls-ca).",
+		redirectErr.Location)
+
+	return true
+}
+
+// reportUnresolvableRedirect explains a redirect to a hostname which does not
+// resolve from here, as happens with canonical names that are only resolvable
+// on the cluster's side of a NAT.  It returns whether it did.
+func (d *diagnoser) reportUnresolvableRedirect(err error) bool {
+	redirectErr, ok := err.(*redirectError)
+	if !ok {
+		return false
+	}
+
+	location, parseErr := url.Parse(redirectErr.Location)
+	if parseErr != nil || location.Hostname() == "" {
+		return false
+	}
+
+	host := location.Hostname()
+	if net.ParseIP(host) != nil || d.reportedRedirectHosts[host] {
+		return false
+	}
+	d.redactHost(host)
+
+	addrs, lookupErr := d.lookupHost(host)
+	if lookupErr == nil && len(addrs) > 0 {
+		return false
+	}
+
+	d.reportedRedirectHosts[host] = true
+	d.errorf(findingRedirectUnresolvable,
+		"The cluster redirected bootstrap to `%s`, which your client cannot resolve.  This usually"+
+			" means the cluster advertises an internal DNS name, such as one only resolvable behind"+
+			" a NAT, and SDKs following the redirect will fail to connect.",
+		host)
+
+	return true
+}
+
+// tlsEnforcedPorts are the TLS ports probed when plaintext bootstrapping failed,
+// and tlsEnforcedPlainPorts their plaintext counterparts which must be closed
+var (
+	tlsEnforcedPorts      = []int{defaultServicePorts["mgmtSSL"], defaultServicePorts["kvSSL"]}
+	tlsEnforcedPlainPorts = []int{defaultServicePorts["mgmt"], defaultServicePorts["kv"]}
+)
+
+// checkTLSEnforced looks for a cluster which enforces TLS after plaintext
+// bootstrapping failed, such clusters close their plaintext ports but still
+// complete TLS handshakes on the secured ones.  It returns whether it did.
+func (d *diagnoser) checkTLSEnforced(spec gocbconnstr.ResolvedConnSpec) bool {
+	if spec.UseSsl || d.reportedTLSRedirect {
+		return d.reportedTLSRedirect
+	}
+
+	for _, host := range seedHostNames(spec) {
+		if d.anyPortOpen(host, tlsEnforcedPlainPorts) {
+			continue
+		}
+
+		for _, port := range tlsEnforcedPorts {
+			conn, err := d.dialer.Dial("tcp", helpers.JoinHostPort(host, port))
+			if err != nil {
+				continue
+			}
+
+			conn.SetDeadline(time.Now().Add(d.timeout))
+			tlsConn := tls.Client(conn, &tls.Config{
+				ServerName:         host,
+				InsecureSkipVerify: true,
+			})
+			err = tlsConn.Handshake()
+			tlsConn.Close()
+			if err != nil {
+				d.log.Log("TLS port `%s:%d` is open, but the TLS handshake failed (error: %s)",
+					host, port, err.Error())
+				continue
+			}
+
+			d.reportedTLSRedirect = true
+			d.errorf(findingTLSRedirect,
+				"This cluster enforces TLS: its plaintext ports are closed, while"+
+					" `%s:%d` accepts TLS connections.  Switch your connection string to the `couchbases://`"+
+					" scheme (and specify the cluster's certificate authority with --tls-ca).",
+				host, port)
+			return true
+		}
+	}
+
+	return false
+}
+
+// anyPortOpen returns whether any of the ports on host accepts TCP connections
+func (d *diagnoser) anyPortOpen(host string, ports []int) bool {
+	for _, port := range ports {
+		conn, err := d.dialer.Dial("tcp", helpers.JoinHostPort(host, port))
+		if err == nil {
+			conn.Close()
+			return true
+		}
+	}
+	return false
+}
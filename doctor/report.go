@@ -0,0 +1,257 @@
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+	"github.com/fatih/color"
+)
+
+// Report contains the results of a diagnostics run
+type Report struct {
+	// Label is the user provided label the run was annotated with, if any
+	Label string `json:"label,omitempty"`
+
+	ConnStr  string    `json:"connStr"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+
+	// Interrupted is set when the run was cancelled before all phases ran
+	Interrupted bool `json:"interrupted"`
+
+	// Phases lists the phases which ran, phases missing from it were skipped
+	Phases  []string           `json:"phases"`
+	Entries []helpers.LogEntry `json:"entries"`
+
+	// Bootstrap names the method the cluster configuration was fetched with,
+	// and BootstrapFallback why a preferred method was not used, both are
+	// empty if bootstrapping failed or was not reached
+	Bootstrap         string `json:"bootstrap,omitempty"`
+	BootstrapFallback string `json:"bootstrapFallback,omitempty"`
+
+	// Endpoints lists the service endpoints the cluster advertises, if requested
+	Endpoints []Endpoint `json:"endpoints,omitempty"`
+
+	// Latencies lists the KV latency measured to each node which replied
+	Latencies []KVLatency `json:"latencies,omitempty"`
+
+	// Nodes lists the hostnames of the cluster's nodes, or of the seed hosts
+	// if the cluster configuration could not be fetched
+	Nodes []string `json:"nodes,omitempty"`
+}
+
+// KVLatency describes the latency of the NOOPs sent to a KV node
+type KVLatency struct {
+	Host string        `json:"host"`
+	Port int           `json:"port"`
+	Mean time.Duration `json:"mean"`
+	P95  time.Duration `json:"p95"`
+}
+
+func (report Report) messages(match func(entry helpers.LogEntry) bool) []string {
+	var out []string
+	for _, entry := range report.Entries {
+		if match(entry) {
+			out = append(out, entry.Message)
+		}
+	}
+	return out
+}
+
+// Details returns the informational findings which belong in the summary
+func (report Report) Details() []string {
+	return report.messages(func(entry helpers.LogEntry) bool {
+		return entry.Level == helpers.LogInfo && entry.Detail
+	})
+}
+
+// Warnings returns the messages of all warnings that were emitted
+func (report Report) Warnings() []string {
+	return report.messages(func(entry helpers.LogEntry) bool {
+		return entry.Level == helpers.LogWarn
+	})
+}
+
+// Errors returns the messages of all errors that were emitted
+func (report Report) Errors() []string {
+	return report.messages(func(entry helpers.LogEntry) bool {
+		return entry.Level == helpers.LogError
+	})
+}
+
+// PhaseRan returns whether the named phase was reached during the run
+func (report Report) PhaseRan(name string) bool {
+	for _, phase := range report.Phases {
+		if phase == name {
+			return true
+		}
+	}
+	return false
+}
+
+// PhaseEntries returns the entries that were emitted during the named phase
+func (report Report) PhaseEntries(name string) []helpers.LogEntry {
+	var out []helpers.LogEntry
+	for _, entry := range report.Entries {
+		if entry.Phase == name {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+// HasIssues returns whether any warnings or errors were emitted
+func (report Report) HasIssues() bool {
+	return len(report.Warnings()) > 0 || len(report.Errors()) > 0
+}
+
+// nodePhases lists the phases which probe each node individually
+var nodePhases = map[string]bool{
+	phaseDNS:         true,
+	phaseSeedPorts:   true,
+	phaseServices:    true,
+	phasePerformance: true,
+	phaseIdle:        true,
+}
+
+// mentionsHost returns whether message refers to host as a whole hostname,
+// rather than as part of a longer one
+func mentionsHost(message, host string) bool {
+	isHostChar := func(c byte) bool {
+		return c == '-' || c == '.' || c == '_' ||
+			(c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	}
+
+	for offset := 0; offset < len(message); {
+		idx := strings.Index(message[offset:], host)
+		if idx == -1 {
+			return false
+		}
+
+		start, end := offset+idx, offset+idx+len(host)
+		if (start == 0 || !isHostChar(message[start-1])) && (end == len(message) || !isHostChar(message[end])) {
+			return true
+		}
+		offset = end
+	}
+	return false
+}
+
+// PrintSummaryByNode prints every entry which mentions a node under that node,
+// so that all findings for a node can be read together, followed by the
+// findings which do not concern any particular node
+func (report Report) PrintSummaryByNode(w io.Writer) {
+	if report.Label != "" {
+		fmt.Fprintf(w, "Summary by node (%s):\n", report.Label)
+	} else {
+		fmt.Fprintf(w, "Summary by node:\n")
+	}
+
+	printEntry := func(entry helpers.LogEntry) {
+		tag := color.CyanString("[INFO]")
+		if entry.Level == helpers.LogWarn {
+			tag = color.YellowString("[WARN]")
+		} else if entry.Level == helpers.LogError {
+			tag = color.RedString("[ERRO]")
+		}
+
+		fmt.Fprintf(w, "  %s (%s) %s\n", tag, entry.Phase, entry.CodedMessage())
+		if entry.Suggestion != "" {
+			fmt.Fprintf(w, "         %s %s\n", color.GreenString("Suggestion:"), entry.Suggestion)
+		}
+	}
+
+	// Besides findings, the steps which probe nodes individually tell what
+	//  worked for a node, such as which services it could be reached on.
+	relevant := func(entry helpers.LogEntry) bool {
+		if entry.Level != helpers.LogInfo || entry.Detail {
+			return true
+		}
+		return nodePhases[entry.Phase] && !strings.Contains(entry.Message, "\n")
+	}
+
+	matched := make([]bool, len(report.Entries))
+	for _, node := range report.Nodes {
+		fmt.Fprintf(w, "\nNode `%s`:\n", node)
+		for i, entry := range report.Entries {
+			if relevant(entry) && mentionsHost(entry.Message, helpers.StripIPv6Brackets(node)) {
+				matched[i] = true
+				printEntry(entry)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "\nCluster:\n")
+	for i, entry := range report.Entries {
+		if !matched[i] && (entry.Level != helpers.LogInfo || entry.Detail) {
+			printEntry(entry)
+		}
+	}
+
+	fmt.Fprintf(w, "\n")
+	if report.Interrupted {
+		fmt.Fprintf(w, "Diagnostics were interrupted, the results above are incomplete.\n")
+	}
+	if report.HasIssues() {
+		fmt.Fprintf(w, "Found multiple issues, see listing above.\n")
+	} else {
+		fmt.Fprintf(w, "Nothing of importance to note!  Nice job!\n")
+	}
+}
+
+// PrintSummary prints a summary of the emitted findings
+func (report Report) PrintSummary(w io.Writer) {
+	if report.Label != "" {
+		fmt.Fprintf(w, "Summary (%s):\n", report.Label)
+	} else {
+		fmt.Fprintf(w, "Summary:\n")
+	}
+
+	if report.Bootstrap != "" {
+		line := "Bootstrapped via " + report.Bootstrap
+		if report.BootstrapFallback != "" {
+			line += ", because " + report.BootstrapFallback
+		}
+		fmt.Fprintf(w, "%s %s\n", color.CyanString("[INFO]"), line)
+	} else if report.PhaseRan(phaseBootstrap) && !report.Interrupted {
+		fmt.Fprintf(w, "%s Bootstrapping failed with every method\n", color.CyanString("[INFO]"))
+	}
+
+	for _, line := range report.Details() {
+		fmt.Fprintf(w, "%s %s\n", color.CyanString("[INFO]"), line)
+	}
+	printFindings := func(level helpers.LogLevel, tag string) {
+		for _, entry := range report.Entries {
+			if entry.Level != level {
+				continue
+			}
+
+			fmt.Fprintf(w, "%s %s\n", tag, entry.CodedMessage())
+			if entry.Suggestion != "" {
+				fmt.Fprintf(w, "       %s %s\n", color.GreenString("Suggestion:"), entry.Suggestion)
+			}
+		}
+	}
+	printFindings(helpers.LogWarn, color.YellowString("[WARN]"))
+	printFindings(helpers.LogError, color.RedString("[ERRO]"))
+
+	if len(report.Endpoints) > 0 {
+		fmt.Fprintf(w, "\nEndpoints:\n")
+		for _, endpoint := range report.Endpoints {
+			fmt.Fprintf(w, "%s\n", endpoint)
+		}
+	}
+
+	fmt.Fprintf(w, "\n")
+	if report.Interrupted {
+		fmt.Fprintf(w, "Diagnostics were interrupted, the results above are incomplete.\n")
+	}
+	if report.HasIssues() {
+		fmt.Fprintf(w, "Found multiple issues, see listing above.\n")
+	} else {
+		fmt.Fprintf(w, "Nothing of importance to note!  Nice job!\n")
+	}
+}
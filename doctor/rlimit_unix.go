@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package doctor
+
+import "syscall"
+
+// openFileLimit returns the soft and hard limits on open files of this process
+func openFileLimit() (soft, hard uint64, ok bool) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0, 0, false
+	}
+	return uint64(limit.Cur), uint64(limit.Max), true
+}
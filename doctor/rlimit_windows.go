@@ -0,0 +1,7 @@
+package doctor
+
+// openFileLimit returns the soft and hard limits on open files of this process,
+// Windows has no such limit to check.
+func openFileLimit() (soft, hard uint64, ok bool) {
+	return 0, 0, false
+}
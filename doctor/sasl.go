@@ -0,0 +1,63 @@
+package doctor
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// reportSASLMechanism logs how a KV connection authenticated, and warns when the
+// credentials had to be sent in cleartext over a connection which is not secured.
+func (d *diagnoser) reportSASLMechanism(client *helpers.MemdClient, host string, port int) {
+	mech := client.SASLMechanism()
+	offered := client.SASLMechanisms()
+
+	d.log.Log("Authenticated to `%s:%d` using the %s SASL mechanism (server offers: %s)",
+		host, port, mech, strings.Join(offered, ", "))
+
+	if mech != "PLAIN" {
+		return
+	}
+
+	if d.tlsConfig != nil {
+		for _, offeredMech := range offered {
+			if strings.HasPrefix(offeredMech, "SCRAM-") {
+				d.log.Log("The server also offers SCRAM mechanisms, PLAIN is used as the connection is secured by TLS")
+				break
+			}
+		}
+		return
+	}
+
+	d.warnf(findingPlainWithoutTLS,
+		"KV connections to `%s:%d` authenticate using PLAIN without TLS, which sends credentials"+
+			" in cleartext, as the server does not offer any SCRAM mechanism.  Use the `couchbases://`"+
+			" scheme to secure connections to this cluster.",
+		host, port)
+}
+
+// checkKVAuth reports when the management service accepted the credentials,
+// but the Key Value service rejected them, which means the user's RBAC roles
+// allow managing the cluster without granting access to the bucket's data.
+func (d *diagnoser) checkKVAuth(err error, host string, port int, bucket string, poolsProbe *poolsProbeResult) {
+	if d.reportedKVAuthMismatch || poolsProbe == nil || poolsProbe.DefaultStatus != 200 {
+		return
+	}
+
+	if errors.Is(err, helpers.ErrAuthFailed) {
+		d.reportedKVAuthMismatch = true
+		d.errorf(findingKVAuthRejected,
+			"The management service at `%s:%d` accepted your credentials, but the Key Value service"+
+				" at `%s:%d` rejected them.  Applications will be able to see the cluster, but not read"+
+				" or write any data.",
+			poolsProbe.Host, poolsProbe.Port, host, port)
+	} else if errors.Is(err, helpers.ErrNoBucketAccess) {
+		d.reportedKVAuthMismatch = true
+		d.errorf(findingKVBucketAccess,
+			"The management service at `%s:%d` accepted your credentials, but the Key Value service"+
+				" at `%s:%d` does not allow the user to access bucket `%s`.  Applications will be able"+
+				" to see the cluster, but not read or write any data.",
+			poolsProbe.Host, poolsProbe.Port, host, port, bucket)
+	}
+}
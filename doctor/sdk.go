@@ -0,0 +1,158 @@
+package doctor
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sdkProfile describes the behavior of one major version of an SDK which
+// changes what the doctor should check and how severe findings are
+type sdkProfile struct {
+	Name  string
+	Major int
+
+	// CCCP and HTTP are the bootstrap methods the SDK attempts
+	CCCP bool
+	HTTP bool
+
+	// Collections is set when the SDK can address scopes and collections
+	Collections bool
+
+	// SyncDurability is set when the SDK supports durability levels
+	SyncDurability bool
+
+	// MinTLSVersion is the oldest TLS version the SDK accepts by default
+	MinTLSVersion uint16
+}
+
+// String returns the name and major version of the SDK, such as `Java SDK 3.x`
+func (profile sdkProfile) String() string {
+	return fmt.Sprintf("%s %d.x", profile.Name, profile.Major)
+}
+
+// sdkProfiles lists the SDKs the doctor can adjust its checks to, keyed by
+// the name used with --sdk
+var sdkProfiles = map[string][]sdkProfile{
+	"java": {
+		{"Java SDK", 1, false, true, false, false, tls.VersionTLS10},
+		{"Java SDK", 2, true, true, false, false, tls.VersionTLS10},
+		{"Java SDK", 3, true, true, true, true, tls.VersionTLS12},
+	},
+	"dotnet": {
+		{".NET SDK", 1, false, true, false, false, tls.VersionTLS10},
+		{".NET SDK", 2, true, true, false, false, tls.VersionTLS10},
+		{".NET SDK", 3, true, true, true, true, tls.VersionTLS12},
+	},
+	"go": {
+		{"Go SDK", 1, true, true, false, false, tls.VersionTLS10},
+		{"Go SDK", 2, true, true, true, true, tls.VersionTLS12},
+	},
+	"node": {
+		{"Node.js SDK", 2, true, true, false, false, tls.VersionTLS10},
+		{"Node.js SDK", 3, true, true, true, true, tls.VersionTLS12},
+		{"Node.js SDK", 4, true, true, true, true, tls.VersionTLS12},
+	},
+	"python": {
+		{"Python SDK", 2, true, true, false, false, tls.VersionTLS10},
+		{"Python SDK", 3, true, true, true, true, tls.VersionTLS12},
+		{"Python SDK", 4, true, true, true, true, tls.VersionTLS12},
+	},
+	"php": {
+		{"PHP SDK", 2, true, true, false, false, tls.VersionTLS10},
+		{"PHP SDK", 3, true, true, true, true, tls.VersionTLS12},
+		{"PHP SDK", 4, true, true, true, true, tls.VersionTLS12},
+	},
+	"ruby": {
+		{"Ruby SDK", 3, true, true, true, true, tls.VersionTLS12},
+	},
+	"c": {
+		{"C SDK (libcouchbase)", 2, true, true, false, false, tls.VersionTLS10},
+		{"C SDK (libcouchbase)", 3, true, true, true, true, tls.VersionTLS12},
+	},
+	"cxx": {
+		{"C++ SDK", 1, true, true, true, true, tls.VersionTLS12},
+	},
+	"scala": {
+		{"Scala SDK", 1, true, true, true, true, tls.VersionTLS12},
+	},
+	"kotlin": {
+		{"Kotlin SDK", 1, true, true, true, true, tls.VersionTLS12},
+	},
+}
+
+// sdkAliases maps other common names of SDKs to their key in sdkProfiles
+var sdkAliases = map[string]string{
+	"gocb":         "go",
+	".net":         "dotnet",
+	"net":          "dotnet",
+	"nodejs":       "node",
+	"libcouchbase": "c",
+	"lcb":          "c",
+	"c++":          "cxx",
+}
+
+// SDKs returns the names accepted for Options.SDK
+func SDKs() []string {
+	var out []string
+	for name, profiles := range sdkProfiles {
+		for _, profile := range profiles {
+			out = append(out, fmt.Sprintf("%s/%d", name, profile.Major))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// lookupSDK finds the profile of an SDK given as `name/version`, where only
+// the major version is significant
+func lookupSDK(sdk string) (*sdkProfile, error) {
+	sep := strings.IndexAny(sdk, "/@")
+	if sep == -1 {
+		return nil, fmt.Errorf("invalid SDK `%s`, expected name/version such as `java/3.4`", sdk)
+	}
+
+	name := strings.ToLower(strings.TrimSpace(sdk[:sep]))
+	if alias, found := sdkAliases[name]; found {
+		name = alias
+	}
+
+	version := strings.TrimPrefix(strings.TrimSpace(sdk[sep+1:]), "v")
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid SDK version `%s`, expected a version such as `3.4`", version)
+	}
+
+	for _, profile := range sdkProfiles[name] {
+		if profile.Major == major {
+			return &profile, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown SDK `%s` (expected one of: %s)", sdk, strings.Join(SDKs(), ", "))
+}
+
+// checkSDKCapabilities warns about the requested features which the SDK the
+// application uses does not support
+func (d *diagnoser) checkSDKCapabilities() {
+	if d.sdk == nil {
+		return
+	}
+
+	d.log.Detail("Adjusting checks to the behavior of the %s", d.sdk)
+
+	if !d.sdk.Collections && (d.opts.Scope != "" || d.opts.Collection != "") {
+		d.errorf(findingSDKUnsupported,
+			"The %s does not support scopes and collections, so the application can only use the"+
+				" default collection of each bucket.  Upgrade to a version which supports them.",
+			d.sdk)
+	}
+
+	if !d.sdk.SyncDurability && d.opts.Durability != "" && d.opts.Durability != "none" {
+		d.errorf(findingSDKUnsupported,
+			"The %s does not support durability levels such as `%s`, only the older observe-based"+
+				" durability.  Upgrade to a version which supports them.",
+			d.sdk, d.opts.Durability)
+	}
+}
@@ -0,0 +1,104 @@
+package doctor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/couchbaselabs/gocbconnstr"
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// seedPortResult holds the outcome of connecting to a single port of a seed host
+type seedPortResult struct {
+	Service string
+	Port    int
+	Err     error
+}
+
+// seedPorts returns the ports to check on a seed host, keyed by port and naming
+// the service expected there: the ports the connection string specifies, and the
+// well-known ports of every service
+func seedPorts(spec gocbconnstr.ResolvedConnSpec, host string) map[int]string {
+	ports := make(map[int]string)
+	for service, port := range defaultServicePorts {
+		if strings.HasSuffix(service, "SSL") == spec.UseSsl {
+			ports[port] = service
+		}
+	}
+
+	kvKey, mgmtKey := "kv", "mgmt"
+	if spec.UseSsl {
+		kvKey, mgmtKey = "kvSSL", "mgmtSSL"
+	}
+	for _, address := range spec.MemdHosts {
+		if address.Host == host {
+			ports[address.Port] = kvKey
+		}
+	}
+	for _, address := range spec.HttpHosts {
+		if address.Host == host {
+			ports[address.Port] = mgmtKey
+		}
+	}
+
+	return ports
+}
+
+// checkSeedPorts connects to the service ports of every seed host, so that the
+// reason a bootstrap failed can be narrowed down even without a config
+func (d *diagnoser) checkSeedPorts(spec gocbconnstr.ResolvedConnSpec) {
+	for _, host := range seedHostNames(spec) {
+		ports := seedPorts(spec, host)
+		results := make([]seedPortResult, 0, len(ports))
+
+		var lock sync.Mutex
+		var waitGroup sync.WaitGroup
+		for port, service := range ports {
+			waitGroup.Add(1)
+			go func(port int, service string) {
+				defer waitGroup.Done()
+
+				conn, err := d.dialer.Dial("tcp", helpers.JoinHostPort(host, port))
+				if err == nil {
+					conn.Close()
+				}
+
+				lock.Lock()
+				results = append(results, seedPortResult{
+					Service: service,
+					Port:    port,
+					Err:     err,
+				})
+				lock.Unlock()
+			}(port, service)
+		}
+		waitGroup.Wait()
+
+		sort.Slice(results, func(i, j int) bool {
+			return results[i].Port < results[j].Port
+		})
+
+		var open, closed []string
+		for _, result := range results {
+			if result.Err == nil {
+				d.log.Log("Port %d (%s) on `%s` is reachable", result.Port, result.Service, host)
+				open = append(open, fmt.Sprintf("%d", result.Port))
+			} else {
+				d.log.Log("Port %d (%s) on `%s` is not reachable: %s (error: %s)",
+					result.Port, result.Service, host, classifyBootstrapError(result.Err), result.Err.Error())
+				closed = append(closed, fmt.Sprintf("%d (%s)", result.Port, classifyBootstrapError(result.Err)))
+			}
+		}
+
+		if len(open) == 0 {
+			open = append(open, "none")
+		}
+		if len(closed) == 0 {
+			closed = append(closed, "none")
+		}
+		d.log.Detail("Seed host `%s` reachable ports: %s, unreachable ports: %s",
+			host, strings.Join(open, ", "), strings.Join(closed, ", "))
+	}
+}
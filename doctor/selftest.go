@@ -0,0 +1,140 @@
+package doctor
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// selfTestHost is a public host used to check DNS resolution and outbound connectivity
+	selfTestHost = "www.couchbase.com"
+
+	// selfTestMaxClockSkew is how far the local clock may drift before it is reported,
+	//  certificate validation starts failing in confusing ways well before this is noticed.
+	selfTestMaxClockSkew = time.Minute
+
+	// selfTestMinOpenFiles is the open file limit below which connection-pooling SDKs
+	//  are at risk of running out of sockets under load.
+	selfTestMinOpenFiles = 4096
+)
+
+// selfTestProxyVars lists the environment variables which route traffic through a proxy
+var selfTestProxyVars = []string{
+	"HTTP_PROXY", "http_proxy",
+	"HTTPS_PROXY", "https_proxy",
+	"ALL_PROXY", "all_proxy",
+}
+
+// selfTest checks the machine the doctor runs on, so that problems with the local
+// environment are not mistaken for problems with the cluster.
+func (d *diagnoser) selfTest() {
+	d.log.Log("Checking the local environment")
+
+	_, err := d.lookupHost("localhost")
+	if err != nil {
+		d.errorf(findingLocalhostUnresolvable,
+			"Failed to resolve `localhost` (error: %s).  The hosts file of this machine"+
+				" appears to be broken.",
+			err.Error())
+	} else {
+		d.log.Log("Resolved `localhost` successfully")
+	}
+
+	publicAddrs, err := d.lookupHost(selfTestHost)
+	if err != nil {
+		d.warnf(findingPublicDNS,
+			"Failed to resolve the public host `%s` (error: %s).  This is expected in air-gapped"+
+				" environments, otherwise the DNS resolver of this machine may be unreachable.",
+			selfTestHost, err.Error())
+	} else {
+		d.log.Log("Resolved the public host `%s` to %s", selfTestHost, strings.Join(publicAddrs, ", "))
+	}
+
+	softLimit, hardLimit, ok := openFileLimit()
+	if ok {
+		d.log.Log("Open file limit is %d (hard limit: %d)", softLimit, hardLimit)
+		if softLimit < selfTestMinOpenFiles {
+			d.warnf(findingLowOpenFileLimit,
+				"The open file limit of this machine is %d (hard limit: %d), which is low for an"+
+					" application using a Couchbase SDK.  Every connection uses a file descriptor, and"+
+					" running out of them makes connections fail under load.",
+				softLimit, hardLimit)
+		}
+	}
+
+	var setProxyVars []string
+	for _, name := range selfTestProxyVars {
+		if value := os.Getenv(name); value != "" {
+			setProxyVars = append(setProxyVars, fmt.Sprintf("%s=%s", name, value))
+		}
+	}
+	if len(setProxyVars) > 0 {
+		d.warnf(findingProxyEnvironment,
+			"Proxy environment variables are set (%s).  The doctor always connects directly, but"+
+				" applications and tools honoring these variables may route cluster traffic through"+
+				" the proxy.  Make sure your cluster hosts are listed in NO_PROXY.",
+			strings.Join(setProxyVars, ", "))
+	}
+
+	now := time.Now()
+	if now.Year() < 2020 {
+		d.errorf(findingClockSkew,
+			"The local clock is set to %s, which is clearly wrong.  TLS certificate"+
+				" validation will fail until the clock is set correctly.",
+			now.Format(time.RFC1123))
+	}
+
+	if publicAddrs == nil {
+		d.log.Log("Skipping the outbound connectivity check, as `%s` did not resolve", selfTestHost)
+		return
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: d.dialer.DialContext,
+		},
+		Timeout: 5000 * time.Millisecond,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://%s/", selfTestHost), nil)
+	if err != nil {
+		return
+	}
+
+	resp, err := client.Do(req.WithContext(d.ctx))
+	if err != nil {
+		d.warnf(findingOutboundConnectivity,
+			"Outbound connectivity to `%s` failed (error: %s).  This is expected in air-gapped"+
+				" environments, otherwise a firewall may be blocking outbound connections.",
+			selfTestHost, err.Error())
+		return
+	}
+	closeResponse(resp)
+
+	d.log.Log("Outbound connectivity to `%s` succeeded (status code: %d)", selfTestHost, resp.StatusCode)
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		d.log.Log("Could not check the local clock, as `%s` did not return a valid date", selfTestHost)
+		return
+	}
+
+	skew := now.Sub(serverTime)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > selfTestMaxClockSkew {
+		d.warnf(findingClockSkew,
+			"The local clock differs from the time reported by `%s` by %s.  A skewed clock can cause"+
+				" TLS certificate validation failures, and makes correlating logs with the cluster harder.",
+			selfTestHost, skew.Round(time.Second))
+	} else {
+		d.log.Log("The local clock is within %s of the time reported by `%s`", selfTestMaxClockSkew, selfTestHost)
+	}
+}
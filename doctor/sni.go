@@ -0,0 +1,61 @@
+package doctor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"time"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// tlsHandshake completes a TLS handshake with host:port presenting serverName,
+// verifying the certificate as configured for the run
+func (d *diagnoser) tlsHandshake(host string, port int, serverName string) error {
+	conn, err := d.dialer.Dial("tcp", helpers.JoinHostPort(host, port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tlsConfig := d.tlsConfig.Clone()
+	tlsConfig.ServerName = serverName
+
+	conn.SetDeadline(time.Now().Add(d.timeout))
+	return tls.Client(conn, tlsConfig).Handshake()
+}
+
+// checkSNIOverride reports whether certificate verification against host:port
+// only succeeds with the overridden server name, which means the certificate
+// lacks a subject alternative name for the address clients connect to.
+func (d *diagnoser) checkSNIOverride(host string, port int) {
+	if d.tlsConfig == nil || d.tlsConfig.InsecureSkipVerify {
+		d.log.Log("Not checking the TLS server name override, as server certificates are not verified")
+		return
+	}
+
+	overrideErr := d.tlsHandshake(host, port, d.opts.TLSServerName)
+	if overrideErr != nil {
+		d.log.Log("TLS handshake with `%s:%d` using server name `%s` failed (error: %s)",
+			host, port, d.opts.TLSServerName, overrideErr.Error())
+		return
+	}
+
+	defaultName := helpers.StripIPv6Brackets(host)
+	defaultErr := d.tlsHandshake(host, port, defaultName)
+
+	var hostnameErr x509.HostnameError
+	if defaultErr == nil {
+		d.log.Log("The certificate of `%s:%d` is valid for `%s` as well, the TLS server name override"+
+			" is not needed", host, port, defaultName)
+	} else if errors.As(defaultErr, &hostnameErr) {
+		d.warnf(findingTLSNameMismatch,
+			"The certificate of `%s:%d` is only accepted using the overridden server name `%s`, it is"+
+				" not valid for `%s` (error: %s).  SDKs verify the certificate against the hostname"+
+				" they connect to, so add `%s` to the certificate's subject alternative names.",
+			host, port, d.opts.TLSServerName, defaultName, defaultErr.Error(), defaultName)
+	} else {
+		d.log.Log("TLS handshake with `%s:%d` using server name `%s` failed (error: %s)",
+			host, port, defaultName, defaultErr.Error())
+	}
+}
@@ -0,0 +1,80 @@
+package doctor
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// terseStatus requests the terse configuration of bucket from a management
+// endpoint, with or without credentials, and returns the status code
+func (d *diagnoser) terseStatus(host string, port int, bucket string, withAuth bool) (int, error) {
+	scheme := "http"
+	if d.tlsConfig != nil {
+		scheme = "https"
+	}
+
+	uri := fmt.Sprintf("%s://%s/pools/default/b/%s", scheme, helpers.JoinHostPort(host, port), url.PathEscape(bucket))
+	req, _ := http.NewRequest("GET", uri, nil)
+	if withAuth {
+		user := d.opts.Username
+		if user == "" {
+			user = bucket
+		}
+		req.SetBasicAuth(user, d.opts.Password)
+	}
+
+	resp, _, err := d.doHTTP(req)
+	if err != nil {
+		return 0, err
+	}
+	defer closeResponse(resp)
+
+	return resp.StatusCode, nil
+}
+
+// checkTerseAuth reports whether the terse configuration endpoint of a node
+// requires authentication, which tells whether the provided credentials are
+// used at all when bootstrapping over HTTP.
+func (d *diagnoser) checkTerseAuth(host string, port int, bucket string) {
+	anonStatus, err := d.terseStatus(host, port, bucket, false)
+	if err != nil {
+		d.log.Log("Could not determine whether `%s:%d` requires authentication for the terse"+
+			" configuration (error: %s)", host, port, err)
+		return
+	}
+
+	authStatus, err := d.terseStatus(host, port, bucket, true)
+	if err != nil {
+		d.log.Log("Could not determine whether `%s:%d` requires authentication for the terse"+
+			" configuration (error: %s)", host, port, err)
+		return
+	}
+
+	switch {
+	case anonStatus == 200 && authStatus == 200:
+		d.log.Detail("The terse configuration of bucket `%s` at `%s:%d` is readable without"+
+			" credentials, so bootstrapping over HTTP does not verify the provided credentials."+
+			"  Authentication failures reported by other services, such as Key Value, are not"+
+			" bootstrap problems.",
+			bucket, host, port)
+	case anonStatus == 200:
+		d.warnf(findingCredentialsRejected,
+			"The terse configuration of bucket `%s` at `%s:%d` is readable without"+
+				" credentials, but not with the provided credentials (status code: %d).  Check the"+
+				" username and password, as SDKs which send them will fail to bootstrap.",
+			bucket, host, port, authStatus)
+	case anonStatus == 401 && authStatus == 200:
+		d.log.Detail("The terse configuration of bucket `%s` at `%s:%d` requires authentication,"+
+			" and the provided credentials were accepted", bucket, host, port)
+	case anonStatus == 401 && authStatus == 401:
+		d.log.Detail("The terse configuration of bucket `%s` at `%s:%d` requires authentication,"+
+			" and the provided credentials were rejected", bucket, host, port)
+	default:
+		d.log.Log("The terse configuration of bucket `%s` at `%s:%d` could not be fetched"+
+			" (status code: %d without credentials, %d with credentials)",
+			bucket, host, port, anonStatus, authStatus)
+	}
+}
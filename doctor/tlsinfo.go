@@ -0,0 +1,127 @@
+package doctor
+
+import (
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+)
+
+// minRSAKeyBits is the smallest RSA key size which is not considered weak
+const minRSAKeyBits = 2048
+
+// minECDSAKeyBits is the smallest ECDSA curve size which is not considered weak
+const minECDSAKeyBits = 256
+
+var tlsVersionNames = map[uint16]string{
+	tls.VersionSSL30: "SSL 3.0",
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+var tlsCipherSuiteNames = map[uint16]string{
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA:            "TLS_RSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA:            "TLS_RSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256:         "TLS_RSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384:         "TLS_RSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA:    "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA:    "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA:      "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA:      "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:   "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:   "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305:    "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305",
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305:  "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305",
+	tls.TLS_AES_128_GCM_SHA256:                  "TLS_AES_128_GCM_SHA256",
+	tls.TLS_AES_256_GCM_SHA384:                  "TLS_AES_256_GCM_SHA384",
+	tls.TLS_CHACHA20_POLY1305_SHA256:            "TLS_CHACHA20_POLY1305_SHA256",
+}
+
+func tlsVersionName(version uint16) string {
+	if name, found := tlsVersionNames[version]; found {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
+
+// certificateKeyStrength describes the public key of cert, such as `RSA-2048`
+// or `ECDSA-P256`, and returns whether the key is too weak to be trusted
+func certificateKeyStrength(cert *x509.Certificate) (string, bool) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		bits := key.N.BitLen()
+		return fmt.Sprintf("RSA-%d", bits), bits < minRSAKeyBits
+	case *ecdsa.PublicKey:
+		params := key.Curve.Params()
+		return "ECDSA-" + strings.Replace(params.Name, "-", "", -1), params.BitSize < minECDSAKeyBits
+	case ed25519.PublicKey:
+		return "Ed25519", false
+	case *dsa.PublicKey:
+		return fmt.Sprintf("DSA-%d", key.P.BitLen()), true
+	}
+	return cert.PublicKeyAlgorithm.String(), false
+}
+
+func tlsCipherSuiteName(suite uint16) string {
+	if name, found := tlsCipherSuiteNames[suite]; found {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", suite)
+}
+
+// reportTLSState logs the TLS protocol version and cipher suite a service
+// negotiated, and warns when the protocol version is outdated.
+func (d *diagnoser) reportTLSState(svcName, host string, port int, state *tls.ConnectionState) {
+	if state == nil {
+		return
+	}
+
+	d.log.Log("%s service at `%s:%d` negotiated %s using %s",
+		svcName, host, port, tlsVersionName(state.Version), tlsCipherSuiteName(state.CipherSuite))
+
+	if d.sdk != nil && state.Version < d.sdk.MinTLSVersion {
+		d.errorf(findingOutdatedTLS,
+			"%s service at `%s:%d` negotiated the outdated %s protocol, which the %s refuses by"+
+				" default.  Configure the cluster's minimum TLS version to at least %s.",
+			svcName, host, port, tlsVersionName(state.Version), d.sdk, tlsVersionName(d.sdk.MinTLSVersion))
+	} else if state.Version < tls.VersionTLS12 {
+		d.warnf(findingOutdatedTLS,
+			"%s service at `%s:%d` negotiated the outdated %s protocol.  Versions older than TLS 1.2"+
+				" are insecure, and many SDKs and clusters refuse them.  Configure the cluster's minimum"+
+				" TLS version to at least TLS 1.2.",
+			svcName, host, port, tlsVersionName(state.Version))
+	}
+
+	if len(state.PeerCertificates) == 0 {
+		return
+	}
+
+	// Services of a node usually share a certificate, only report its key once
+	cert := state.PeerCertificates[0]
+	fingerprint := sha256.Sum256(cert.Raw)
+	if d.reportedCertKeys[fingerprint] {
+		return
+	}
+	d.reportedCertKeys[fingerprint] = true
+
+	keyName, weak := certificateKeyStrength(cert)
+	d.log.Log("%s service at `%s:%d` presented a certificate for `%s` (key: %s, signature: %s)",
+		svcName, host, port, cert.Subject.CommonName, keyName, cert.SignatureAlgorithm)
+
+	if weak {
+		d.warnf(findingWeakCertKey,
+			"%s service at `%s:%d` presented a certificate with a weak %s key.  Keys this small can be"+
+				" broken, and security audits and some SDKs reject them.  Use at least RSA-2048 or"+
+				" ECDSA-P256 keys for the cluster's certificates.",
+			svcName, host, port, keyName)
+	}
+}
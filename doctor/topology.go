@@ -0,0 +1,141 @@
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// serviceReachability records, per node hostname and service key, whether the
+// service could be connected to during the services phase
+type serviceReachability map[string]map[string]bool
+
+func (reach serviceReachability) set(host, service string, reachable bool) {
+	if reach[host] == nil {
+		reach[host] = make(map[string]bool)
+	}
+	reach[host][service] = reachable
+}
+
+// Endpoint is a service endpoint advertised by a node of the cluster
+type Endpoint struct {
+	Service string `json:"service"`
+	Host    string `json:"host"`
+	Port    int    `json:"port"`
+	SSL     bool   `json:"ssl"`
+
+	// Tested is set when the doctor attempted to connect to the endpoint, and
+	// Reachable when it succeeded
+	Tested    bool `json:"tested"`
+	Reachable bool `json:"reachable"`
+}
+
+func (endpoint Endpoint) String() string {
+	reachable := "unknown"
+	if endpoint.Tested {
+		reachable = strconv.FormatBool(endpoint.Reachable)
+	}
+	return fmt.Sprintf("%s %s ssl=%t reachable=%s",
+		endpoint.Service, helpers.JoinHostPort(endpoint.Host, endpoint.Port), endpoint.SSL, reachable)
+}
+
+// discoveredEndpoints lists every endpoint advertised by nodes, sorted by node
+// and service
+func discoveredEndpoints(nodes []clusterNode, reach serviceReachability) []Endpoint {
+	var out []Endpoint
+	for _, node := range nodes {
+		services := make([]string, 0, len(node.Services))
+		for service, port := range node.Services {
+			if port != 0 {
+				services = append(services, service)
+			}
+		}
+		sort.Strings(services)
+
+		for _, service := range services {
+			reachable, tested := reach[node.Hostname][service]
+			out = append(out, Endpoint{
+				Service:   service,
+				Host:      node.Hostname,
+				Port:      node.Services[service],
+				SSL:       strings.HasSuffix(service, "SSL") || strings.HasSuffix(strings.ToUpper(service), "HTTPS"),
+				Tested:    tested,
+				Reachable: reachable,
+			})
+		}
+	}
+	return out
+}
+
+// topologyServices lists the services the services phase tests, in the order
+// they are drawn
+var topologyServices = []struct {
+	Name     string
+	PlainKey string
+	SSLKey   string
+}{
+	{"Key Value", "kv", "kvSSL"},
+	{"Management", "mgmt", "mgmtSSL"},
+	{"Views", "capi", "capiSSL"},
+	{"Query", "n1ql", "n1qlSSL"},
+	{"Search", "fts", "ftsSSL"},
+	{"Analytics", "cbas", "cbasSSL"},
+	{"Index", "indexHttp", "indexHttps"},
+	{"Backup", "backupAPI", "backupAPIHTTPS"},
+}
+
+// writeTopologyDot writes a Graphviz DOT description of the cluster's nodes,
+// the services each runs and whether the doctor could reach them.
+func writeTopologyDot(w io.Writer, nodes []clusterNode, reach serviceReachability, useSsl bool) error {
+	var out strings.Builder
+
+	out.WriteString("digraph couchbase {\n")
+	out.WriteString("  rankdir=LR;\n")
+	out.WriteString("  node [shape=box, style=rounded];\n")
+	out.WriteString("  client [label=\"sdk-doctor\", shape=ellipse];\n")
+
+	for i, node := range nodes {
+		fmt.Fprintf(&out, "\n  subgraph cluster_node%d {\n", i)
+		fmt.Fprintf(&out, "    label=%q;\n", node.Hostname)
+
+		var edges []string
+		for _, service := range topologyServices {
+			svcKey := service.PlainKey
+			if useSsl {
+				svcKey = service.SSLKey
+			}
+
+			port := node.Services[svcKey]
+			if port == 0 {
+				continue
+			}
+
+			id := fmt.Sprintf("node%d_%s", i, service.PlainKey)
+			fmt.Fprintf(&out, "    %s [label=%q];\n", id, fmt.Sprintf("%s\n:%d", service.Name, port))
+
+			reachable, tested := reach[node.Hostname][svcKey]
+			switch {
+			case !tested:
+				edges = append(edges, fmt.Sprintf("  client -> %s [color=gray, style=dotted];\n", id))
+			case reachable:
+				edges = append(edges, fmt.Sprintf("  client -> %s [color=green];\n", id))
+			default:
+				edges = append(edges, fmt.Sprintf("  client -> %s [color=red, style=dashed, label=\"unreachable\"];\n", id))
+			}
+		}
+		out.WriteString("  }\n")
+
+		for _, edge := range edges {
+			out.WriteString(edge)
+		}
+	}
+
+	out.WriteString("}\n")
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
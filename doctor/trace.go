@@ -0,0 +1,72 @@
+package doctor
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/couchbaselabs/sdk-doctor/helpers"
+)
+
+// redactedHeaders lists the headers whose values are masked in traces, so that
+// logs can be shared without leaking credentials.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// tracingTransport logs every HTTP exchange passing through it (--trace-http)
+type tracingTransport struct {
+	next http.RoundTripper
+	log  *helpers.Logger
+}
+
+func (transport *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	lines := []string{fmt.Sprintf("%s %s %s", req.Method, req.URL.RequestURI(), req.Proto),
+		fmt.Sprintf("Host: %s", req.URL.Host)}
+	lines = append(lines, formatTraceHeaders(req.Header)...)
+	transport.log.Log("HTTP request to `%s`:\n  %s", req.URL.Host, strings.Join(lines, "\n  "))
+
+	resp, err := transport.next.RoundTrip(req)
+	if err != nil {
+		transport.log.Log("HTTP request to `%s` failed (error: %s)", req.URL.Host, err.Error())
+		return resp, err
+	}
+
+	lines = []string{fmt.Sprintf("%s %s", resp.Proto, resp.Status)}
+	lines = append(lines, formatTraceHeaders(resp.Header)...)
+	transport.log.Log("HTTP response from `%s`:\n  %s", req.URL.Host, strings.Join(lines, "\n  "))
+
+	return resp, nil
+}
+
+func formatTraceHeaders(header http.Header) []string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		for _, value := range header[name] {
+			if redactedHeaders[name] {
+				value = redactHeaderValue(value)
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", name, value))
+		}
+	}
+	return lines
+}
+
+// redactHeaderValue keeps the authentication scheme, which is useful for
+// diagnosis, but masks the credentials themselves.
+func redactHeaderValue(value string) string {
+	if parts := strings.SplitN(value, " ", 2); len(parts) == 2 {
+		return parts[0] + " <redacted>"
+	}
+	return "<redacted>"
+}
@@ -7,4 +7,6 @@ require (
 	github.com/fatih/color v1.9.0
 	github.com/spf13/cobra v1.0.0
 	github.com/spf13/viper v1.7.0
+	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
 )
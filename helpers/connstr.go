@@ -0,0 +1,117 @@
+package helpers
+
+import (
+	"net"
+	"strings"
+
+	"github.com/couchbaselabs/gocbconnstr"
+)
+
+// ConnStrHost is a host and port a connection string resolved to
+type ConnStrHost struct {
+	Host string
+	Port int
+}
+
+// ResolvedConnStr describes what a connection string resolves to, the same
+// way the SDKs resolve it
+type ResolvedConnStr struct {
+	// Scheme is the scheme of the connection string, or empty if it had none
+	Scheme string
+
+	// Bucket is the bucket named by the connection string, or empty if none
+	Bucket string
+
+	// UseSSL is set when the connection string requires secured connections
+	UseSSL bool
+
+	// SRVRecord is the name of the DNS SRV record the connection string may
+	// refer to, or empty if it cannot refer to one
+	SRVRecord string
+
+	// FromSRV is set when the CCCP hosts were taken from the DNS SRV record,
+	// in which case there are no HTTP hosts
+	FromSRV bool
+
+	// CCCPHosts are the hosts to fetch the cluster config from over memcached
+	CCCPHosts []ConnStrHost
+
+	// HTTPHosts are the hosts to fetch the cluster config from over HTTP
+	HTTPHosts []ConnStrHost
+
+	// Options holds every option of the connection string, by name
+	Options map[string][]string
+
+	// Spec is the connection string as written, before it was resolved
+	Spec gocbconnstr.ConnSpec
+}
+
+// SRVLookupFunc looks up the DNS SRV records of name
+type SRVLookupFunc func(name string) ([]*net.SRV, error)
+
+// ResolveConnStr parses connStr and resolves it into the hosts to bootstrap
+// against, looking up its DNS SRV record using the system resolver if it
+// may refer to one.
+func ResolveConnStr(connStr string) (*ResolvedConnStr, error) {
+	return ResolveConnStrWith(connStr, nil)
+}
+
+// ResolveConnStrWith is like ResolveConnStr, but also looks up the DNS SRV
+// record using lookupSRV, if not nil.  The records it finds take precedence
+// over those of the system resolver, which are used when it finds none.
+func ResolveConnStrWith(connStr string, lookupSRV SRVLookupFunc) (*ResolvedConnStr, error) {
+	connSpec, err := gocbconnstr.Parse(strings.TrimSpace(connStr))
+	if err != nil {
+		return nil, err
+	}
+
+	resConnSpec, err := gocbconnstr.Resolve(connSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &ResolvedConnStr{
+		Scheme:    connSpec.Scheme,
+		Bucket:    resConnSpec.Bucket,
+		UseSSL:    resConnSpec.UseSsl,
+		SRVRecord: connSpec.SrvRecordName(),
+		CCCPHosts: connStrHosts(resConnSpec.MemdHosts),
+		HTTPHosts: connStrHosts(resConnSpec.HttpHosts),
+		Options:   make(map[string][]string, len(resConnSpec.Options)),
+		Spec:      connSpec,
+	}
+
+	if lookupSRV != nil && out.SRVRecord != "" {
+		srvAddrs, _ := lookupSRV(out.SRVRecord)
+		if len(srvAddrs) > 0 {
+			out.CCCPHosts = make([]ConnStrHost, 0, len(srvAddrs))
+			out.HTTPHosts = nil
+			for _, addr := range srvAddrs {
+				out.CCCPHosts = append(out.CCCPHosts, ConnStrHost{
+					Host: strings.TrimSuffix(addr.Target, "."),
+					Port: int(addr.Port),
+				})
+			}
+		}
+	}
+
+	// Hosts listed in the connection string always resolve to HTTP hosts too
+	out.FromSRV = out.SRVRecord != "" && len(out.HTTPHosts) == 0
+
+	for name, values := range resConnSpec.Options {
+		out.Options[name] = append([]string(nil), values...)
+	}
+
+	return out, nil
+}
+
+func connStrHosts(addresses []gocbconnstr.Address) []ConnStrHost {
+	out := make([]ConnStrHost, 0, len(addresses))
+	for _, address := range addresses {
+		out = append(out, ConnStrHost{
+			Host: address.Host,
+			Port: address.Port,
+		})
+	}
+	return out
+}
@@ -0,0 +1,208 @@
+package helpers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestResolveConnStrSchemes(t *testing.T) {
+	tests := []struct {
+		connStr   string
+		useSSL    bool
+		cccpHosts []ConnStrHost
+		httpHosts []ConnStrHost
+	}{
+		{
+			connStr:   "couchbase://node1,node2",
+			cccpHosts: []ConnStrHost{{"node1", 11210}, {"node2", 11210}},
+			httpHosts: []ConnStrHost{{"node1", 8091}, {"node2", 8091}},
+		},
+		{
+			connStr:   "couchbases://node1,node2",
+			useSSL:    true,
+			cccpHosts: []ConnStrHost{{"node1", 11207}, {"node2", 11207}},
+			httpHosts: []ConnStrHost{{"node1", 18091}, {"node2", 18091}},
+		},
+		{
+			connStr:   "http://node1,node2",
+			cccpHosts: []ConnStrHost{{"node1", 11210}, {"node2", 11210}},
+			httpHosts: []ConnStrHost{{"node1", 8091}, {"node2", 8091}},
+		},
+		{
+			connStr:   "node1,node2",
+			cccpHosts: []ConnStrHost{{"node1", 11210}, {"node2", 11210}},
+			httpHosts: []ConnStrHost{{"node1", 8091}, {"node2", 8091}},
+		},
+		{
+			connStr:   "couchbase://",
+			cccpHosts: []ConnStrHost{{"127.0.0.1", 11210}},
+			httpHosts: []ConnStrHost{{"127.0.0.1", 8091}},
+		},
+		{
+			connStr:   "couchbases://",
+			useSSL:    true,
+			cccpHosts: []ConnStrHost{{"127.0.0.1", 11207}},
+			httpHosts: []ConnStrHost{{"127.0.0.1", 18091}},
+		},
+	}
+
+	for _, test := range tests {
+		resolved, err := ResolveConnStr(test.connStr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.connStr, err)
+			continue
+		}
+
+		if resolved.UseSSL != test.useSSL {
+			t.Errorf("%s: expected UseSSL %t, got %t", test.connStr, test.useSSL, resolved.UseSSL)
+		}
+		if !reflect.DeepEqual(resolved.CCCPHosts, test.cccpHosts) {
+			t.Errorf("%s: expected CCCP hosts %v, got %v", test.connStr, test.cccpHosts, resolved.CCCPHosts)
+		}
+		if !reflect.DeepEqual(resolved.HTTPHosts, test.httpHosts) {
+			t.Errorf("%s: expected HTTP hosts %v, got %v", test.connStr, test.httpHosts, resolved.HTTPHosts)
+		}
+		if resolved.FromSRV {
+			t.Errorf("%s: expected the hosts not to come from a DNS SRV record", test.connStr)
+		}
+	}
+}
+
+func TestResolveConnStrExplicitPorts(t *testing.T) {
+	resolved, err := ResolveConnStr("couchbase://node1:11210,node2:12000")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expectedCCCP := []ConnStrHost{{"node1", 11210}, {"node2", 12000}}
+	if !reflect.DeepEqual(resolved.CCCPHosts, expectedCCCP) {
+		t.Errorf("expected CCCP hosts %v, got %v", expectedCCCP, resolved.CCCPHosts)
+	}
+
+	// Only hosts on the default port can be bootstrapped against over HTTP too
+	expectedHTTP := []ConnStrHost{{"node1", 8091}}
+	if !reflect.DeepEqual(resolved.HTTPHosts, expectedHTTP) {
+		t.Errorf("expected HTTP hosts %v, got %v", expectedHTTP, resolved.HTTPHosts)
+	}
+}
+
+func TestResolveConnStrInvalid(t *testing.T) {
+	for _, connStr := range []string{
+		"ftp://node1",
+		"couchbase://node1:8091",
+		"node1:11210",
+	} {
+		if _, err := ResolveConnStr(connStr); err == nil {
+			t.Errorf("%s: expected an error", connStr)
+		}
+	}
+}
+
+func TestResolveConnStrBucketAndOptions(t *testing.T) {
+	resolved, err := ResolveConnStr("  couchbase://node1,node2/travel-sample?network=external&kv_timeout=5s  ")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if resolved.Scheme != "couchbase" {
+		t.Errorf("expected scheme `couchbase`, got `%s`", resolved.Scheme)
+	}
+	if resolved.Bucket != "travel-sample" {
+		t.Errorf("expected bucket `travel-sample`, got `%s`", resolved.Bucket)
+	}
+
+	expectedOptions := map[string][]string{
+		"network":    {"external"},
+		"kv_timeout": {"5s"},
+	}
+	if !reflect.DeepEqual(resolved.Options, expectedOptions) {
+		t.Errorf("expected options %v, got %v", expectedOptions, resolved.Options)
+	}
+
+	// The options are copied, so that callers cannot modify the parsed spec
+	resolved.Options["network"][0] = "default"
+	if resolved.Spec.Options["network"][0] != "external" {
+		t.Errorf("expected the options to be copied")
+	}
+
+	resolved, err = ResolveConnStr("couchbase://node1,node2")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if resolved.Bucket != "" {
+		t.Errorf("expected no bucket, got `%s`", resolved.Bucket)
+	}
+}
+
+func TestResolveConnStrSRV(t *testing.T) {
+	var lookedUp string
+	lookupSRV := func(name string) ([]*net.SRV, error) {
+		lookedUp = name
+		return []*net.SRV{
+			{Target: "node1.example.invalid.", Port: 11210},
+			{Target: "node2.example.invalid.", Port: 11210},
+		}, nil
+	}
+
+	resolved, err := ResolveConnStrWith("couchbase://example.invalid", lookupSRV)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if resolved.SRVRecord != "_couchbase._tcp.example.invalid" {
+		t.Errorf("expected SRV record `_couchbase._tcp.example.invalid`, got `%s`", resolved.SRVRecord)
+	}
+	if lookedUp != resolved.SRVRecord {
+		t.Errorf("expected `%s` to be looked up, got `%s`", resolved.SRVRecord, lookedUp)
+	}
+	if !resolved.FromSRV {
+		t.Errorf("expected the hosts to come from the DNS SRV record")
+	}
+
+	expectedCCCP := []ConnStrHost{{"node1.example.invalid", 11210}, {"node2.example.invalid", 11210}}
+	if !reflect.DeepEqual(resolved.CCCPHosts, expectedCCCP) {
+		t.Errorf("expected CCCP hosts %v, got %v", expectedCCCP, resolved.CCCPHosts)
+	}
+	if len(resolved.HTTPHosts) != 0 {
+		t.Errorf("expected no HTTP hosts, got %v", resolved.HTTPHosts)
+	}
+}
+
+func TestResolveConnStrSRVNotFound(t *testing.T) {
+	lookupSRV := func(name string) ([]*net.SRV, error) {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	resolved, err := ResolveConnStrWith("couchbases://example.invalid", lookupSRV)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if resolved.SRVRecord != "_couchbases._tcp.example.invalid" {
+		t.Errorf("expected SRV record `_couchbases._tcp.example.invalid`, got `%s`", resolved.SRVRecord)
+	}
+	if resolved.FromSRV {
+		t.Errorf("expected the hosts not to come from a DNS SRV record")
+	}
+
+	expectedCCCP := []ConnStrHost{{"example.invalid", 11207}}
+	if !reflect.DeepEqual(resolved.CCCPHosts, expectedCCCP) {
+		t.Errorf("expected CCCP hosts %v, got %v", expectedCCCP, resolved.CCCPHosts)
+	}
+}
+
+func TestResolveConnStrNoSRVForHostList(t *testing.T) {
+	lookupSRV := func(name string) ([]*net.SRV, error) {
+		t.Errorf("unexpected DNS SRV lookup of `%s`", name)
+		return nil, nil
+	}
+
+	resolved, err := ResolveConnStrWith("couchbase://node1:11210", lookupSRV)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if resolved.SRVRecord != "" {
+		t.Errorf("expected no SRV record, got `%s`", resolved.SRVRecord)
+	}
+}
@@ -2,63 +2,206 @@ package helpers
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
 	"time"
+)
+
+// LogLevel specifies the severity of a log entry
+type LogLevel int
 
-	"github.com/fatih/color"
+// Various log levels that can be used
+const (
+	LogInfo LogLevel = iota
+	LogWarn
+	LogError
 )
 
-// Logger provides aggregated logging
+// String returns the short name used when printing the level
+func (level LogLevel) String() string {
+	switch level {
+	case LogWarn:
+		return "WARN"
+	case LogError:
+		return "ERRO"
+	}
+	return "INFO"
+}
+
+// MarshalText encodes the level by its lower-cased name
+func (level LogLevel) MarshalText() ([]byte, error) {
+	switch level {
+	case LogWarn:
+		return []byte("warn"), nil
+	case LogError:
+		return []byte("error"), nil
+	}
+	return []byte("info"), nil
+}
+
+// UnmarshalText decodes a level from its lower-cased name
+func (level *LogLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "info":
+		*level = LogInfo
+	case "warn":
+		*level = LogWarn
+	case "error":
+		*level = LogError
+	default:
+		return fmt.Errorf("unknown log level `%s`", text)
+	}
+	return nil
+}
+
+// LogEntry represents a single line written to the log
+type LogEntry struct {
+	Time    time.Time `json:"time"`
+	Level   LogLevel  `json:"level"`
+	Phase   string    `json:"phase,omitempty"`
+	Message string    `json:"message"`
+
+	// Detail marks informational entries which belong in the summary
+	Detail bool `json:"detail,omitempty"`
+
+	// Finding identifies the kind of problem a warning or error reports, Code
+	// is its stable short code, and Suggestion is a short hint on how to fix it
+	Finding    string `json:"finding,omitempty"`
+	Code       string `json:"code,omitempty"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// CodedMessage returns the message prefixed by the finding's code, if any
+func (entry LogEntry) CodedMessage() string {
+	if entry.Code == "" {
+		return entry.Message
+	}
+	return "[" + entry.Code + "] " + entry.Message
+}
+
+// Logger provides aggregated logging, it is safe for concurrent use
 type Logger struct {
-	warns  []string
-	errors []string
+	lock    sync.Mutex
+	out     io.Writer
+	phase   string
+	phases  []string
+	entries []LogEntry
+
+	redactor *Redactor
 }
 
-func timeLogStr() string {
-	t := time.Now()
+// NewLogger creates a Logger writing to out, or discarding output if out is nil
+func NewLogger(out io.Writer) *Logger {
+	if out == nil {
+		out = ioutil.Discard
+	}
+
+	return &Logger{
+		out: out,
+	}
+}
+
+func timeLogStr(t time.Time) string {
 	return fmt.Sprintf("%02d:%02d:%02d.%03d",
 		t.Hour(), t.Minute(), t.Second(), t.Nanosecond()/int(time.Millisecond))
 }
 
+func (l *Logger) write(level LogLevel, detail bool, format string, args ...interface{}) {
+	l.writeEntry(LogEntry{
+		Level:   level,
+		Message: fmt.Sprintf(format, args...),
+		Detail:  detail,
+	})
+}
+
+func (l *Logger) writeEntry(entry LogEntry) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	entry.Time = time.Now()
+	entry.Phase = l.phase
+	if l.redactor != nil {
+		entry.Message = l.redactor.Redact(entry.Message)
+	}
+
+	fmt.Fprintf(l.out, "%s %s ▶ %s\n", timeLogStr(entry.Time), entry.Level, entry.CodedMessage())
+	l.entries = append(l.entries, entry)
+}
+
+// SetRedactor makes the logger redact every following entry using r
+func (l *Logger) SetRedactor(r *Redactor) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.redactor = r
+}
+
+// SetPhase attributes all following entries to the named phase
+func (l *Logger) SetPhase(name string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if name == l.phase {
+		return
+	}
+
+	l.phase = name
+	l.phases = append(l.phases, name)
+}
+
+// Phases returns the names of the phases that were entered, in order
+func (l *Logger) Phases() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.phases
+}
+
 // NewLine adds a new line to the log
 func (l *Logger) NewLine() {
-	fmt.Printf("\n")
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	fmt.Fprintf(l.out, "\n")
 }
 
 // Log writes to the log at INFO level
 func (l *Logger) Log(format string, args ...interface{}) {
-	line := fmt.Sprintf(format, args...)
-	fmt.Printf("%s INFO ▶ %s\n", timeLogStr(), line)
+	l.write(LogInfo, false, format, args...)
+}
+
+// Detail writes to the log at INFO level and includes the line in the summary
+func (l *Logger) Detail(format string, args ...interface{}) {
+	l.write(LogInfo, true, format, args...)
 }
 
 // Warn writes to the log at WARN level
 func (l *Logger) Warn(format string, args ...interface{}) {
-	line := fmt.Sprintf(format, args...)
-	fmt.Printf("%s WARN ▶ %s\n", timeLogStr(), line)
-	l.warns = append(l.warns, line)
+	l.write(LogWarn, false, format, args...)
 }
 
 // Error writes to the log at ERROR level
 func (l *Logger) Error(format string, args ...interface{}) {
-	line := fmt.Sprintf(format, args...)
-	fmt.Printf("%s ERRO ▶ %s\n", timeLogStr(), line)
-	l.errors = append(l.errors, line)
+	l.write(LogError, false, format, args...)
 }
 
-// PrintSummary prints a summary of the emitted logs
-func (l Logger) PrintSummary() {
-	fmt.Printf("Summary:\n")
+// Finding writes a warning or error identifying a particular kind of problem
+// by its code, along with a suggestion on how to fix it
+func (l *Logger) Finding(level LogLevel, finding, code, suggestion, format string, args ...interface{}) {
+	l.writeEntry(LogEntry{
+		Level:      level,
+		Message:    fmt.Sprintf(format, args...),
+		Finding:    finding,
+		Code:       code,
+		Suggestion: suggestion,
+	})
+}
 
-	for _, line := range l.warns {
-		fmt.Printf("%s %s\n", color.YellowString("[WARN]"), line)
-	}
-	for _, line := range l.errors {
-		fmt.Printf("%s %s\n", color.RedString("[ERRO]"), line)
-	}
+// Entries returns every entry written to the log so far
+func (l *Logger) Entries() []LogEntry {
+	l.lock.Lock()
+	defer l.lock.Unlock()
 
-	fmt.Printf("\n")
-	if len(l.warns) > 0 || len(l.errors) > 0 {
-		fmt.Printf("Found multiple issues, see listing above.\n")
-	} else {
-		fmt.Printf("Nothing of importance to note!  Nice job!\n")
-	}
+	return l.entries
 }
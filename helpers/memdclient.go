@@ -2,8 +2,12 @@ package helpers
 
 import (
 	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
+	"net"
 	"strings"
 	"time"
 
@@ -13,39 +17,70 @@ import (
 // MemdClient provides a memcached client
 type MemdClient struct {
 	conn memd.ReadWriteCloser
+
+	saslMechs []string
+	saslMech  string
+
+	timeout time.Duration
+}
+
+// DefaultMemdTimeout bounds how long a TLS handshake, authenticating and
+// selecting a bucket, or a request made by a MemdClient may take
+const DefaultMemdTimeout = 2000 * time.Millisecond
+
+// ErrAuthFailed is returned when the server rejects the credentials
+var ErrAuthFailed = errors.New("invalid bucket name/password")
+
+// ErrErrorMapUnsupported is returned when the server cannot provide an error map
+var ErrErrorMapUnsupported = errors.New("error map is not supported")
+
+// ErrNoBucketAccess is returned when the user may not access the selected bucket
+var ErrNoBucketAccess = errors.New("user does not have access to the bucket")
+
+// Dial will dial a particular host using dialer and return a MemdClient
+func Dial(dialer memd.NetDialer, host string, port int, bucket, user, pass string, tlsConfig *tls.Config) (*MemdClient, error) {
+	return DialTimeout(dialer, host, port, bucket, user, pass, tlsConfig, DefaultMemdTimeout)
 }
 
-// Dial will dial a particular host and return a MemdClient
-func Dial(host string, port int, bucket, user, pass string, tlsConfig *tls.Config) (*MemdClient, error) {
+// DialTimeout is like Dial, but bounds setting up the connection, and each
+// request made by the client, by timeout instead of DefaultMemdTimeout
+func DialTimeout(dialer memd.NetDialer, host string, port int, bucket, user, pass string,
+	tlsConfig *tls.Config, timeout time.Duration) (*MemdClient, error) {
 	if user == "" {
 		user = bucket
 	}
 
-	address := fmt.Sprintf("%s:%d", host, port)
-
-	deadline := time.Now().Add(time.Millisecond * 2000)
+	address := JoinHostPort(host, port)
 
 	var srvTLSConfig *tls.Config
 	if tlsConfig != nil {
 		srvTLSConfig = tlsConfig.Clone()
-		srvTLSConfig.ServerName = host
+		if srvTLSConfig.ServerName == "" {
+			srvTLSConfig.ServerName = StripIPv6Brackets(host)
+		}
 	}
 
-	conn, err := memd.DialMemdConn(address, srvTLSConfig, deadline)
+	conn, err := memd.DialMemdConn(dialer, address, srvTLSConfig, timeout)
 	if err != nil {
 		return nil, err
 	}
 
 	var client MemdClient
 	client.conn = conn
+	client.timeout = timeout
 
-	err = client.auth(user, pass)
+	// Don't wait forever on a server which never replies, such as when a port
+	//  belonging to a different service was used.
+	conn.SetDeadline(time.Now().Add(timeout))
+	defer conn.SetDeadline(time.Time{})
+
+	err = client.auth(user, pass, tlsConfig != nil)
 	if err != nil {
 		client.Close()
 		return nil, err
 	}
 
-	if bucket != user {
+	if bucket != "" && bucket != user {
 		err = client.selectBucket(bucket)
 		if err != nil {
 			client.Close()
@@ -56,12 +91,40 @@ func Dial(host string, port int, bucket, user, pass string, tlsConfig *tls.Confi
 	return &client, nil
 }
 
+// LocalAddr returns the local address the connection was made from
+func (client *MemdClient) LocalAddr() net.Addr {
+	return client.conn.LocalAddr()
+}
+
+// RemoteAddr returns the address the connection was made to
+func (client *MemdClient) RemoteAddr() net.Addr {
+	return client.conn.RemoteAddr()
+}
+
+// TLSConnectionState returns the state of the TLS connection, or nil if the
+// connection is not secured
+func (client *MemdClient) TLSConnectionState() *tls.ConnectionState {
+	return client.conn.TLSConnectionState()
+}
+
 // Close closes a connection
 func (client *MemdClient) Close() {
 	client.conn.Close()
 }
 
-func (client *MemdClient) auth(user, pass string) error {
+// SASLMechanisms returns the SASL mechanisms offered by the server
+func (client *MemdClient) SASLMechanisms() []string {
+	return client.saslMechs
+}
+
+// SASLMechanism returns the SASL mechanism that was used to authenticate
+func (client *MemdClient) SASLMechanism() string {
+	return client.saslMech
+}
+
+// auth authenticates the connection, preferring the strongest SCRAM mechanism
+// over plaintext connections and PLAIN over TLS, as the SDKs do.
+func (client *MemdClient) auth(user, pass string, secure bool) error {
 	var resp memd.Response
 
 	err := client.conn.WritePacket(&memd.Request{
@@ -81,19 +144,34 @@ func (client *MemdClient) auth(user, pass string) error {
 		return errors.New("unexpected SASLListMechs status")
 	}
 
-	mechs := strings.Split(string(resp.Value), " ")
+	client.saslMechs = strings.Fields(string(resp.Value))
 
-	foundPlainMech := false
-	for _, mech := range mechs {
-		if mech == "PLAIN" {
-			foundPlainMech = true
+	offered := make(map[string]bool)
+	for _, mech := range client.saslMechs {
+		offered[mech] = true
+	}
+
+	if !secure {
+		for _, mech := range scramMechs {
+			if offered[mech.Name] {
+				client.saslMech = mech.Name
+				return client.authScram(mech.Name, mech.NewHash, user, pass)
+			}
 		}
 	}
 
-	if !foundPlainMech {
-		return errors.New("server does not support PLAIN SASL")
+	if !offered["PLAIN"] {
+		return fmt.Errorf("server does not support any SASL mechanism supported by the doctor (offered: %s)",
+			strings.Join(client.saslMechs, ", "))
 	}
 
+	client.saslMech = "PLAIN"
+	return client.authPlain(user, pass)
+}
+
+func (client *MemdClient) authPlain(user, pass string) error {
+	var resp memd.Response
+
 	// Build PLAIN auth data
 	userBuf := []byte(user)
 	passBuf := []byte(pass)
@@ -103,7 +181,7 @@ func (client *MemdClient) auth(user, pass string) error {
 	authData[1+len(userBuf)] = 0
 	copy(authData[1+len(userBuf)+1:], passBuf)
 
-	err = client.conn.WritePacket(&memd.Request{
+	err := client.conn.WritePacket(&memd.Request{
 		Magic:  memd.ReqMagic,
 		Opcode: memd.CmdSASLAuth,
 		Key:    []byte("PLAIN"),
@@ -118,15 +196,74 @@ func (client *MemdClient) auth(user, pass string) error {
 		return err
 	}
 
-	if resp.Status != 0 {
-		if resp.Status == memd.StatusAuthError {
-			return errors.New("invalid bucket name/password")
-		}
+	return saslStatusError(resp.Status, user)
+}
 
-		return fmt.Errorf("SASL auth failed for user `%s` (status: %d)", user, resp.Status)
+func (client *MemdClient) authScram(mech string, newHash func() hash.Hash, user, pass string) error {
+	var resp memd.Response
+
+	scram, err := newScramClient(newHash, user, pass)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	err = client.conn.WritePacket(&memd.Request{
+		Magic:  memd.ReqMagic,
+		Opcode: memd.CmdSASLAuth,
+		Key:    []byte(mech),
+		Value:  scram.ClientFirst(),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = client.conn.ReadPacket(&resp)
+	if err != nil {
+		return err
+	}
+
+	if resp.Status != memd.StatusAuthContinue {
+		return saslStatusError(resp.Status, user)
+	}
+
+	clientFinal, err := scram.ClientFinal(resp.Value)
+	if err != nil {
+		return err
+	}
+
+	err = client.conn.WritePacket(&memd.Request{
+		Magic:  memd.ReqMagic,
+		Opcode: memd.CmdSASLStep,
+		Key:    []byte(mech),
+		Value:  clientFinal,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = client.conn.ReadPacket(&resp)
+	if err != nil {
+		return err
+	}
+
+	err = saslStatusError(resp.Status, user)
+	if err != nil {
+		return err
+	}
+
+	return scram.VerifyServerFinal(resp.Value)
+}
+
+func saslStatusError(status memd.StatusCode, user string) error {
+	if status == 0 {
+		return nil
+	}
+
+	if status == memd.StatusAuthError {
+		return ErrAuthFailed
+	}
+
+	return fmt.Errorf("SASL auth failed for user `%s` (status: %d)", user, status)
 }
 
 func (client *MemdClient) selectBucket(bucket string) error {
@@ -146,6 +283,10 @@ func (client *MemdClient) selectBucket(bucket string) error {
 		return err
 	}
 
+	if resp.Status == memd.StatusAccessError {
+		return ErrNoBucketAccess
+	}
+
 	if resp.Status != 0 {
 		return fmt.Errorf("failed to select bucket `%s` (status: %d)", bucket, resp.Status)
 	}
@@ -157,6 +298,9 @@ func (client *MemdClient) selectBucket(bucket string) error {
 func (client *MemdClient) GetConfig() ([]byte, error) {
 	var resp memd.Response
 
+	client.conn.SetDeadline(time.Now().Add(client.timeout))
+	defer client.conn.SetDeadline(time.Time{})
+
 	err := client.conn.WritePacket(&memd.Request{
 		Magic:  memd.ReqMagic,
 		Opcode: memd.CmdGetClusterConfig,
@@ -177,19 +321,123 @@ func (client *MemdClient) GetConfig() ([]byte, error) {
 	return resp.Value, nil
 }
 
+// ErrorMap describes the error map a server returned
+type ErrorMap struct {
+	Version  int `json:"version"`
+	Revision int `json:"revision"`
+	Errors   map[string]struct {
+		Name string `json:"name"`
+	} `json:"errors"`
+}
+
+// GetErrorMap will fetch the server's error map, requesting at most version
+func (client *MemdClient) GetErrorMap(version uint16) (*ErrorMap, error) {
+	var resp memd.Response
+
+	client.conn.SetDeadline(time.Now().Add(client.timeout))
+	defer client.conn.SetDeadline(time.Time{})
+
+	value := make([]byte, 2)
+	binary.BigEndian.PutUint16(value, version)
+
+	err := client.conn.WritePacket(&memd.Request{
+		Magic:  memd.ReqMagic,
+		Opcode: memd.CmdGetErrorMap,
+		Value:  value,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.conn.ReadPacket(&resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Status == memd.StatusUnknownCommand || resp.Status == memd.StatusNotSupported {
+		return nil, ErrErrorMapUnsupported
+	}
+
+	if resp.Status != memd.StatusSuccess {
+		return nil, fmt.Errorf("failed to get error map (status: %d)", resp.Status)
+	}
+
+	var errMap ErrorMap
+	err = json.Unmarshal(resp.Value, &errMap)
+	if err != nil {
+		return nil, err
+	}
+
+	return &errMap, nil
+}
+
+// Hello will negotiate features with the server, identifying the client as
+// name, and returns the requested features which the server enabled
+func (client *MemdClient) Hello(name string, features []memd.HelloFeature) ([]memd.HelloFeature, error) {
+	var resp memd.Response
+
+	client.conn.SetDeadline(time.Now().Add(client.timeout))
+	defer client.conn.SetDeadline(time.Time{})
+
+	value := make([]byte, 2*len(features))
+	for i, feature := range features {
+		binary.BigEndian.PutUint16(value[2*i:], uint16(feature))
+	}
+
+	err := client.conn.WritePacket(&memd.Request{
+		Magic:  memd.ReqMagic,
+		Opcode: memd.CmdHello,
+		Key:    []byte(name),
+		Value:  value,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.conn.ReadPacket(&resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Status != memd.StatusSuccess {
+		return nil, fmt.Errorf("failed to negotiate features (status: %d)", resp.Status)
+	}
+
+	enabled := make([]memd.HelloFeature, 0, len(resp.Value)/2)
+	for i := 0; i+1 < len(resp.Value); i += 2 {
+		enabled = append(enabled, memd.HelloFeature(binary.BigEndian.Uint16(resp.Value[i:])))
+	}
+
+	return enabled, nil
+}
+
 // Ping will send a ping and wait for a response
 func (client *MemdClient) Ping() error {
 	var resp memd.Response
 
-	client.conn.WritePacket(&memd.Request{
+	err := client.conn.WritePacket(&memd.Request{
 		Magic:  memd.ReqMagic,
 		Opcode: memd.CmdNop,
 	})
+	if err != nil {
+		return err
+	}
 
-	err := client.conn.ReadPacket(&resp)
+	err = client.conn.ReadPacket(&resp)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// PingWithTimeout will send a ping and fail if no response arrives in time
+func (client *MemdClient) PingWithTimeout(timeout time.Duration) error {
+	err := client.conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		return err
+	}
+	defer client.conn.SetDeadline(time.Time{})
+
+	return client.Ping()
+}
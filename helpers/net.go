@@ -0,0 +1,21 @@
+package helpers
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// StripIPv6Brackets removes the brackets surrounding an IPv6 literal
+func StripIPv6Brackets(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// JoinHostPort combines host and port into an address, bracketing IPv6
+// literals whether or not host was already bracketed.
+func JoinHostPort(host string, port int) string {
+	return net.JoinHostPort(StripIPv6Brackets(host), strconv.Itoa(port))
+}
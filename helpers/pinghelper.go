@@ -1,6 +1,10 @@
 package helpers
 
-import "time"
+import (
+	"math"
+	"sort"
+	"time"
+)
 
 // PingHelper provides a helper for the statistics of pinging
 type PingHelper struct {
@@ -10,6 +14,7 @@ type PingHelper struct {
 	min          time.Duration
 	max          time.Duration
 	sum          time.Duration
+	samples      []time.Duration
 }
 
 // PingState represents the state of a single ping
@@ -25,6 +30,8 @@ func (ph *PingHelper) StopOne(state PingState, err error) {
 	duration := time.Now().Sub(time.Time(state))
 
 	if err == nil {
+		ph.samples = append(ph.samples, duration)
+
 		if ph.successCount == 0 {
 			ph.min = duration
 			ph.max = duration
@@ -80,3 +87,25 @@ func (ph *PingHelper) Max() time.Duration {
 func (ph *PingHelper) Mean() time.Duration {
 	return time.Duration(float64(ph.sum) / float64(ph.successCount))
 }
+
+// Percentile returns the duration which p percent of the successful pings
+// completed within, using the nearest-rank method
+func (ph *PingHelper) Percentile(p float64) time.Duration {
+	if len(ph.samples) == 0 {
+		return 0
+	}
+
+	sorted := make([]time.Duration, len(ph.samples))
+	copy(sorted, ph.samples)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	} else if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
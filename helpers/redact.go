@@ -0,0 +1,163 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ipv4Pattern and ipv6Pattern find candidate IP literals, which are then
+// confirmed by parsing them
+var (
+	ipv4Pattern = regexp.MustCompile(`\d{1,3}(\.\d{1,3}){3}`)
+	ipv6Pattern = regexp.MustCompile(`[0-9A-Fa-f]{0,4}(:[0-9A-Fa-f]{0,4}){2,7}`)
+)
+
+// Redactor replaces hostnames, IP addresses and bucket names with stable
+// pseudonyms such as `host-1`, so output can be shared without revealing
+// infrastructure details.  It is safe for concurrent use.
+type Redactor struct {
+	lock    sync.Mutex
+	names   map[string]string
+	buckets map[string]bool
+	counts  map[string]int
+	pattern *regexp.Regexp
+}
+
+// NewRedactor creates a Redactor which knows no names yet
+func NewRedactor() *Redactor {
+	return &Redactor{
+		names:   make(map[string]string),
+		buckets: make(map[string]bool),
+		counts:  make(map[string]int),
+	}
+}
+
+func (r *Redactor) add(name, kind string) {
+	if name == "" || r.names[name] != "" {
+		return
+	}
+
+	r.counts[kind]++
+	r.names[name] = fmt.Sprintf("%s-%d", kind, r.counts[kind])
+	r.pattern = nil
+}
+
+// AddHost registers a hostname or IP address to be redacted
+func (r *Redactor) AddHost(host string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	host = StripIPv6Brackets(host)
+	if net.ParseIP(host) != nil {
+		r.add(host, "ip")
+	} else {
+		r.add(host, "host")
+	}
+}
+
+// AddBucket registers a bucket name to be redacted
+func (r *Redactor) AddBucket(bucket string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.add(bucket, "bucket")
+	r.buckets[bucket] = true
+}
+
+// isNameChar returns whether c may be part of a hostname
+func isNameChar(c byte) bool {
+	return c == '-' || c == '.' || c == '_' ||
+		(c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isBucketDelimiter returns whether c may surround a bucket name which is
+// quoted or part of a path, rather than an ordinary word of a message
+func isBucketDelimiter(c byte) bool {
+	return c == '`' || c == '/' || c == '"' || c == '?'
+}
+
+// Redact returns s with every registered name, and any IP address, replaced
+// by its pseudonym
+func (r *Redactor) Redact(s string) string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, pattern := range []*regexp.Regexp{ipv4Pattern, ipv6Pattern} {
+		for _, candidate := range pattern.FindAllString(s, -1) {
+			if net.ParseIP(candidate) != nil {
+				r.add(candidate, "ip")
+			}
+		}
+	}
+
+	if len(r.names) == 0 {
+		return s
+	}
+
+	if r.pattern == nil {
+		names := make([]string, 0, len(r.names))
+		for name := range r.names {
+			names = append(names, regexp.QuoteMeta(name))
+		}
+		// Prefer the longest match, so a hostname is not redacted piece by piece
+		sort.Slice(names, func(i, j int) bool {
+			return len(names[i]) > len(names[j])
+		})
+		r.pattern = regexp.MustCompile(strings.Join(names, "|"))
+	}
+
+	var out strings.Builder
+	last := 0
+	for _, match := range r.pattern.FindAllStringIndex(s, -1) {
+		start, end := match[0], match[1]
+		name := s[start:end]
+
+		if start > 0 && isNameChar(s[start-1]) || end < len(s) && isNameChar(s[end]) {
+			continue
+		}
+
+		// Bucket names are often ordinary words, such as `default`
+		if r.buckets[name] {
+			if start == 0 || !isBucketDelimiter(s[start-1]) || end < len(s) && !isBucketDelimiter(s[end]) {
+				continue
+			}
+			if strings.HasSuffix(s[:start], "/pools/") {
+				continue
+			}
+		}
+
+		out.WriteString(s[last:start])
+		out.WriteString(r.names[name])
+		last = end
+	}
+	out.WriteString(s[last:])
+
+	return out.String()
+}
+
+// Writer returns a writer which redacts everything written through it to out.
+// Each write is redacted as a whole, so callers should write complete documents.
+func (r *Redactor) Writer(out io.Writer) io.Writer {
+	return redactingWriter{
+		redactor: r,
+		out:      out,
+	}
+}
+
+type redactingWriter struct {
+	redactor *Redactor
+	out      io.Writer
+}
+
+func (w redactingWriter) Write(p []byte) (int, error) {
+	_, err := io.WriteString(w.out, w.redactor.Redact(string(p)))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
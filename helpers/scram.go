@@ -0,0 +1,132 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// scramMechs lists the SCRAM mechanisms we support, strongest first
+var scramMechs = []struct {
+	Name    string
+	NewHash func() hash.Hash
+}{
+	{"SCRAM-SHA512", sha512.New},
+	{"SCRAM-SHA256", sha256.New},
+	{"SCRAM-SHA1", sha1.New},
+}
+
+// scramClient carries the state of a single SCRAM exchange (RFC 5802)
+type scramClient struct {
+	newHash func() hash.Hash
+	user    string
+	pass    string
+
+	clientNonce     string
+	clientFirstBare string
+	serverSignature []byte
+}
+
+func newScramClient(newHash func() hash.Hash, user, pass string) (*scramClient, error) {
+	nonceBytes := make([]byte, 18)
+	_, err := rand.Read(nonceBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scramClient{
+		newHash:     newHash,
+		user:        user,
+		pass:        pass,
+		clientNonce: base64.StdEncoding.EncodeToString(nonceBytes),
+	}, nil
+}
+
+// ClientFirst returns the opening message of the exchange
+func (client *scramClient) ClientFirst() []byte {
+	escapedUser := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(client.user)
+	client.clientFirstBare = "n=" + escapedUser + ",r=" + client.clientNonce
+	return []byte("n,," + client.clientFirstBare)
+}
+
+// ClientFinal computes the proof in reply to the server's first message
+func (client *scramClient) ClientFinal(serverFirst []byte) ([]byte, error) {
+	attrs := parseScramAttrs(string(serverFirst))
+
+	nonce := attrs["r"]
+	if !strings.HasPrefix(nonce, client.clientNonce) {
+		return nil, errors.New("server returned an invalid SCRAM nonce")
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil {
+		return nil, fmt.Errorf("server returned an invalid SCRAM salt (error: %s)", err)
+	}
+
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations < 1 {
+		return nil, errors.New("server returned an invalid SCRAM iteration count")
+	}
+
+	clientFinalBare := "c=biws,r=" + nonce
+	authMessage := client.clientFirstBare + "," + string(serverFirst) + "," + clientFinalBare
+
+	saltedPassword := pbkdf2.Key([]byte(client.pass), salt, iterations, client.newHash().Size(), client.newHash)
+	clientKey := client.hmac(saltedPassword, "Client Key")
+	storedKey := client.newHash()
+	storedKey.Write(clientKey)
+	clientSignature := client.hmac(storedKey.Sum(nil), authMessage)
+
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+
+	serverKey := client.hmac(saltedPassword, "Server Key")
+	client.serverSignature = client.hmac(serverKey, authMessage)
+
+	return []byte(clientFinalBare + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// VerifyServerFinal checks the server proved it also knows the password
+func (client *scramClient) VerifyServerFinal(serverFinal []byte) error {
+	attrs := parseScramAttrs(string(serverFinal))
+
+	if attrs["e"] != "" {
+		return fmt.Errorf("server rejected the SCRAM exchange (error: %s)", attrs["e"])
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || subtle.ConstantTimeCompare(signature, client.serverSignature) != 1 {
+		return errors.New("server returned an invalid SCRAM signature")
+	}
+
+	return nil
+}
+
+func (client *scramClient) hmac(key []byte, message string) []byte {
+	mac := hmac.New(client.newHash, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+func parseScramAttrs(message string) map[string]string {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(message, ",") {
+		if len(part) >= 2 && part[1] == '=' {
+			attrs[part[:1]] = part[2:]
+		}
+	}
+	return attrs
+}
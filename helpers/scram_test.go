@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+	"testing"
+)
+
+// scramVectors are the example exchanges of RFC 5802 and RFC 7677
+var scramVectors = []struct {
+	name        string
+	newHash     func() hash.Hash
+	clientNonce string
+	clientFirst string
+	serverFirst string
+	clientFinal string
+	serverFinal string
+}{
+	{
+		name:        "SCRAM-SHA-1 (RFC 5802)",
+		newHash:     sha1.New,
+		clientNonce: "fyko+d2lbbFgONRv9qkxdawL",
+		clientFirst: "n,,n=user,r=fyko+d2lbbFgONRv9qkxdawL",
+		serverFirst: "r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096",
+		clientFinal: "c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts=",
+		serverFinal: "v=rmF9pqV8S7suAoZWja4dJRkFsKQ=",
+	},
+	{
+		name:        "SCRAM-SHA-256 (RFC 7677)",
+		newHash:     sha256.New,
+		clientNonce: "rOprNGfwEbeRWgbNEkqO",
+		clientFirst: "n,,n=user,r=rOprNGfwEbeRWgbNEkqO",
+		serverFirst: "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+		clientFinal: "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0," +
+			"p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+		serverFinal: "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=",
+	},
+}
+
+func TestScramVectors(t *testing.T) {
+	for _, vector := range scramVectors {
+		client := &scramClient{
+			newHash:     vector.newHash,
+			user:        "user",
+			pass:        "pencil",
+			clientNonce: vector.clientNonce,
+		}
+
+		if clientFirst := string(client.ClientFirst()); clientFirst != vector.clientFirst {
+			t.Errorf("%s: expected client first message `%s`, got `%s`", vector.name, vector.clientFirst, clientFirst)
+		}
+
+		clientFinal, err := client.ClientFinal([]byte(vector.serverFirst))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", vector.name, err)
+			continue
+		}
+		if string(clientFinal) != vector.clientFinal {
+			t.Errorf("%s: expected client final message `%s`, got `%s`", vector.name, vector.clientFinal, clientFinal)
+		}
+
+		if err := client.VerifyServerFinal([]byte(vector.serverFinal)); err != nil {
+			t.Errorf("%s: expected the server signature to verify, got: %s", vector.name, err)
+		}
+	}
+}
+
+func TestScramRejectsInvalidServer(t *testing.T) {
+	vector := scramVectors[1]
+	client := &scramClient{
+		newHash:     vector.newHash,
+		user:        "user",
+		pass:        "pencil",
+		clientNonce: vector.clientNonce,
+	}
+	client.ClientFirst()
+
+	if _, err := client.ClientFinal([]byte("r=someoneelse,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")); err == nil {
+		t.Errorf("expected a nonce not extending the client's to be rejected")
+	}
+
+	if _, err := client.ClientFinal([]byte(vector.serverFirst)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := client.VerifyServerFinal([]byte("v=rmF9pqV8S7suAoZWja4dJRkFsKQ=")); err == nil {
+		t.Errorf("expected an invalid server signature to be rejected")
+	}
+	if err := client.VerifyServerFinal([]byte("e=invalid-proof")); err == nil {
+		t.Errorf("expected a server error to be reported")
+	}
+}
@@ -0,0 +1,221 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// socks5Replies describes the failure codes a SOCKS5 proxy may reply with
+var socks5Replies = map[byte]string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// SOCKS5Dialer dials connections through a SOCKS5 proxy (RFC 1928), leaving
+// hostname resolution to the proxy so that hosts only resolvable behind it work.
+type SOCKS5Dialer struct {
+	ProxyAddress string
+	Username     string
+	Password     string
+
+	// Forward is used to connect to the proxy itself
+	Forward *net.Dialer
+}
+
+// NewSOCKS5Dialer creates a SOCKS5Dialer from a proxy specified as
+// `[socks5://][user:password@]host:port`.
+func NewSOCKS5Dialer(proxy string, forward *net.Dialer) (*SOCKS5Dialer, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = "socks5://" + proxy
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOCKS5 proxy `%s` (error: %w)", proxy, err)
+	}
+	if proxyURL.Scheme != "socks5" && proxyURL.Scheme != "socks5h" {
+		return nil, fmt.Errorf("invalid SOCKS5 proxy `%s`, unsupported scheme `%s`", proxy, proxyURL.Scheme)
+	}
+	if proxyURL.Port() == "" {
+		return nil, fmt.Errorf("invalid SOCKS5 proxy `%s`, expected a port", proxy)
+	}
+
+	dialer := &SOCKS5Dialer{
+		ProxyAddress: proxyURL.Host,
+		Forward:      forward,
+	}
+	if proxyURL.User != nil {
+		dialer.Username = proxyURL.User.Username()
+		dialer.Password, _ = proxyURL.User.Password()
+	}
+
+	return dialer, nil
+}
+
+// Dial connects to address through the proxy
+func (dialer *SOCKS5Dialer) Dial(network, address string) (net.Conn, error) {
+	return dialer.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to address through the proxy using the provided context
+func (dialer *SOCKS5Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil, fmt.Errorf("SOCKS5 proxy does not support network `%s`", network)
+	}
+
+	conn, err := dialer.Forward.DialContext(ctx, "tcp", dialer.ProxyAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SOCKS5 proxy `%s` (error: %w)", dialer.ProxyAddress, err)
+	}
+
+	deadline, hasDeadline := ctx.Deadline()
+	if !hasDeadline && dialer.Forward.Timeout > 0 {
+		deadline = time.Now().Add(dialer.Forward.Timeout)
+	}
+	conn.SetDeadline(deadline)
+
+	err = dialer.handshake(conn, address)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SOCKS5 proxy `%s` failed to connect to `%s` (error: %w)",
+			dialer.ProxyAddress, address, err)
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (dialer *SOCKS5Dialer) handshake(conn net.Conn, address string) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+
+	methods := []byte{0x00}
+	if dialer.Username != "" {
+		methods = append(methods, 0x02)
+	}
+
+	_, err = conn.Write(append([]byte{0x05, byte(len(methods))}, methods...))
+	if err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	_, err = io.ReadFull(conn, reply)
+	if err != nil {
+		return err
+	}
+	if reply[0] != 0x05 {
+		return errors.New("server is not a SOCKS5 proxy")
+	}
+
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		err = dialer.authenticate(conn)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("proxy requires an unsupported authentication method")
+	}
+
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return errors.New("hostname is too long")
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, 0x01)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, 0x04)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+
+	_, err = conn.Write(req)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	_, err = io.ReadFull(conn, header)
+	if err != nil {
+		return err
+	}
+	if header[1] != 0x00 {
+		if reason, found := socks5Replies[header[1]]; found {
+			return errors.New(reason)
+		}
+		return fmt.Errorf("unknown SOCKS5 reply (code: %d)", header[1])
+	}
+
+	// Discard the bound address, which we have no use for
+	var boundLen int
+	switch header[3] {
+	case 0x01:
+		boundLen = net.IPv4len
+	case 0x04:
+		boundLen = net.IPv6len
+	case 0x03:
+		lenBuf := make([]byte, 1)
+		_, err = io.ReadFull(conn, lenBuf)
+		if err != nil {
+			return err
+		}
+		boundLen = int(lenBuf[0])
+	default:
+		return errors.New("proxy replied with an unknown address type")
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, boundLen+2))
+	return err
+}
+
+func (dialer *SOCKS5Dialer) authenticate(conn net.Conn) error {
+	if len(dialer.Username) > 255 || len(dialer.Password) > 255 {
+		return errors.New("proxy username or password is too long")
+	}
+
+	req := []byte{0x01, byte(len(dialer.Username))}
+	req = append(req, dialer.Username...)
+	req = append(req, byte(len(dialer.Password)))
+	req = append(req, dialer.Password...)
+
+	_, err := conn.Write(req)
+	if err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	_, err = io.ReadFull(conn, reply)
+	if err != nil {
+		return err
+	}
+	if reply[1] != 0x00 {
+		return errors.New("proxy rejected the username and password")
+	}
+
+	return nil
+}
@@ -70,6 +70,7 @@ const (
 	CmdSubDocCounter        = CommandCode(0xcf)
 	CmdSubDocMultiLookup    = CommandCode(0xd0)
 	CmdSubDocMultiMutation  = CommandCode(0xd1)
+	CmdGetErrorMap          = CommandCode(0xfe)
 )
 
 // SubDocFlag provides flags for packets
@@ -103,8 +104,16 @@ type HelloFeature uint16
 
 // Various feature flags that can be used
 const (
-	FeatureDatatype = HelloFeature(0x01)
-	FeatureSeqNo    = HelloFeature(0x04)
+	FeatureDatatype        = HelloFeature(0x01)
+	FeatureSeqNo           = HelloFeature(0x04)
+	FeatureXattr           = HelloFeature(0x06)
+	FeatureXerror          = HelloFeature(0x07)
+	FeatureSelectBucket    = HelloFeature(0x08)
+	FeatureSnappy          = HelloFeature(0x0a)
+	FeatureJSON            = HelloFeature(0x0b)
+	FeatureAltRequests     = HelloFeature(0x10)
+	FeatureSyncReplication = HelloFeature(0x11)
+	FeatureCollections     = HelloFeature(0x12)
 )
 
 // StatusCode provides the status of a packet
@@ -3,6 +3,7 @@ package memd
 import (
 	"crypto/tls"
 	"encoding/binary"
+	"errors"
 	"io"
 	"net"
 	"time"
@@ -34,46 +35,59 @@ type Response struct {
 	Value    []byte
 }
 
+// ErrNotMemcached is returned when the server responds with something other than a memcached packet
+var ErrNotMemcached = errors.New("server did not respond with a memcached packet")
+
 // Dialer provides an interface for dialing memcached connections
 type Dialer interface {
 	Dial(address string) (io.ReadWriteCloser, error)
 }
 
+// NetDialer provides an interface for establishing the underlying network connection
+type NetDialer interface {
+	Dial(network, address string) (net.Conn, error)
+}
+
 // ReadWriteCloser provides an interface for reading and writing packets
 type ReadWriteCloser interface {
 	WritePacket(*Request) error
 	ReadPacket(*Response) error
+	SetDeadline(time.Time) error
+	LocalAddr() net.Addr
+	RemoteAddr() net.Addr
+	TLSConnectionState() *tls.ConnectionState
 	Close() error
 }
 
 type memdConn struct {
-	conn    io.ReadWriteCloser
+	conn    net.Conn
 	recvBuf []byte
 }
 
-// DialMemdConn dials a memcached connection
-func DialMemdConn(address string, tlsConfig *tls.Config, deadline time.Time) (ReadWriteCloser, error) {
-	d := net.Dialer{
-		Deadline: deadline,
-	}
-
-	baseConn, err := d.Dial("tcp", address)
+// DialMemdConn dials a memcached connection, bounding the TLS handshake by handshakeTimeout
+func DialMemdConn(dialer NetDialer, address string, tlsConfig *tls.Config, handshakeTimeout time.Duration) (ReadWriteCloser, error) {
+	baseConn, err := dialer.Dial("tcp", address)
 	if err != nil {
 		return nil, err
 	}
 
-	tcpConn := baseConn.(*net.TCPConn)
-	tcpConn.SetNoDelay(false)
+	// Connections through a proxy may not be plain TCP connections
+	if tcpConn, ok := baseConn.(*net.TCPConn); ok {
+		tcpConn.SetNoDelay(false)
+	}
 
-	var conn io.ReadWriteCloser
+	var conn net.Conn
 	if tlsConfig == nil {
-		conn = tcpConn
+		conn = baseConn
 	} else {
-		tlsConn := tls.Client(tcpConn, tlsConfig)
+		tlsConn := tls.Client(baseConn, tlsConfig)
+		tlsConn.SetDeadline(time.Now().Add(handshakeTimeout))
 		err = tlsConn.Handshake()
 		if err != nil {
+			baseConn.Close()
 			return nil, err
 		}
+		tlsConn.SetDeadline(time.Time{})
 
 		conn = tlsConn
 	}
@@ -87,6 +101,30 @@ func (s *memdConn) Close() error {
 	return s.conn.Close()
 }
 
+func (s *memdConn) LocalAddr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+func (s *memdConn) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+// TLSConnectionState returns the state of the TLS connection, or nil if the
+// connection is not secured
+func (s *memdConn) TLSConnectionState() *tls.ConnectionState {
+	tlsConn, ok := s.conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+
+	state := tlsConn.ConnectionState()
+	return &state
+}
+
+func (s *memdConn) SetDeadline(deadline time.Time) error {
+	return s.conn.SetDeadline(deadline)
+}
+
 func (s *memdConn) WritePacket(req *Request) error {
 	extLen := len(req.Extras)
 	keyLen := len(req.Key)
@@ -155,6 +193,12 @@ func (s *memdConn) ReadPacket(resp *Response) error {
 		return err
 	}
 
+	// Bail out before trusting the body length of something that is not a
+	//  memcached response, such as an HTTP server answering on this port.
+	if CommandMagic(hdrBuf[0]) != ResMagic {
+		return ErrNotMemcached
+	}
+
 	bodyLen := int(binary.BigEndian.Uint32(hdrBuf[8:]))
 	bodyBuf, err := s.readBuffered(bodyLen)
 	if err != nil {